@@ -0,0 +1,177 @@
+// Package analysis measures how the price moved during trades: excursions, slippage and spread costs
+package analysis
+
+import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/api/tickdata/stream"
+	"github.com/pkg/errors"
+	"math"
+	"time"
+)
+
+type Side int
+
+const (
+	Buy Side = iota
+	Sell
+)
+
+func (s Side) String() string {
+	if s == Sell {
+		return "sell"
+	}
+
+	return "buy"
+}
+
+// ExitLookahead is how long after the close time the exit tick is searched, the close might fall in a weekend
+const ExitLookahead = 72 * time.Hour
+
+// Trade executed at OpenPrice and ClosePrice, times can be in any location. StopLoss and TakeProfit are optional.
+type Trade struct {
+	Side       Side
+	OpenTime   time.Time
+	OpenPrice  float64
+	CloseTime  time.Time
+	ClosePrice float64
+	StopLoss   float64
+	TakeProfit float64
+}
+
+// Excursion of a trade. Prices are followed on the closing side, the bid for buy trades and the ask for sell trades.
+// Distances are in price units, positive in favour of the trade, use Points to convert them.
+type Excursion struct {
+	Trade Trade
+
+	// EntryTick is the first tick at or after the open time, ExitTick the first one at or after the close time
+	EntryTick *tickdata.TickData
+	ExitTick  *tickdata.TickData
+	Ticks     int
+
+	// MAE is the maximum adverse excursion, the worst distance from the open price
+	MAE float64
+	// MFE is the maximum favourable excursion, the best distance from the open price
+	MFE float64
+	// MFETime is when MFE was first reached, in the location of the open time
+	MFETime   time.Time
+	TimeToMFE time.Duration
+	// DrawdownBeforeMFE is the worst distance from the open price until MFETime
+	DrawdownBeforeMFE float64
+
+	// EntrySlippage and ExitSlippage are the distances between the trade prices and the market prices,
+	// positive when the trade was filled at a worse price than the market
+	EntrySlippage float64
+	ExitSlippage  float64
+
+	// SpreadCost is half of the entry spread plus half of the exit spread, what the trade paid against mid prices
+	SpreadCost float64
+
+	// StopLossTime and TakeProfitTime are when the levels were first touched, zero if they weren't
+	StopLossTime   time.Time
+	TakeProfitTime time.Time
+}
+
+// Points converts a price distance into instrument points
+func Points(instrument *instrument.Metadata, distance float64) int {
+	return int(math.Round(distance * instrument.DecimalFactor()))
+}
+
+// Analyze is AnalyzeContext without cancellation
+func Analyze(instrument *instrument.Metadata, trade Trade, downloadFolderPath string, opts ...datafeed.Option) (Excursion, error) {
+	return AnalyzeContext(context.Background(), instrument, trade, downloadFolderPath, opts...)
+}
+
+// AnalyzeContext streams the ticks from the open time until the first tick at or after the close time
+func AnalyzeContext(ctx context.Context, instrument *instrument.Metadata, trade Trade, downloadFolderPath string, opts ...datafeed.Option) (Excursion, error) {
+	e := Excursion{Trade: trade}
+	if trade.CloseTime.Before(trade.OpenTime) {
+		return e, errors.Errorf("trade closes at [%s] before it opens at [%s]", trade.CloseTime, trade.OpenTime)
+	}
+
+	var streamErr error
+	s := stream.New(instrument, trade.OpenTime, trade.CloseTime.Add(ExitLookahead), downloadFolderPath, opts...)
+	err := s.EachTickContext(ctx, func(tickTime time.Time, tick *tickdata.TickData, err error) bool {
+		if err != nil {
+			streamErr = err
+			return false
+		}
+
+		return e.add(tickTime, tick)
+	})
+	if err == nil {
+		err = streamErr
+	}
+	if err != nil {
+		return e, errors.Wrapf(err, "failed to analyze [%s] trade opened at [%s]", instrument.Code(), trade.OpenTime)
+	}
+	if e.ExitTick == nil {
+		return e, errors.Errorf("no [%s] tick after the trade close at [%s]", instrument.Code(), trade.CloseTime)
+	}
+
+	return e, nil
+}
+
+// add the tick to the excursion, returns false once the exit tick is reached
+func (e *Excursion) add(tickTime time.Time, tick *tickdata.TickData) bool {
+	trade := e.Trade
+	if e.EntryTick == nil {
+		e.EntryTick = tick
+		e.MAE = math.Inf(1)
+		e.MFE = math.Inf(-1)
+		if trade.Side == Buy {
+			e.EntrySlippage = trade.OpenPrice - tick.Ask
+		} else {
+			e.EntrySlippage = tick.Bid - trade.OpenPrice
+		}
+		e.SpreadCost = (tick.Ask - tick.Bid) / 2
+	}
+	e.Ticks++
+
+	var distance float64
+	if trade.Side == Buy {
+		distance = tick.Bid - trade.OpenPrice
+	} else {
+		distance = trade.OpenPrice - tick.Ask
+	}
+	e.MAE = math.Min(e.MAE, distance)
+	e.touchLevels(tickTime, tick)
+	if distance > e.MFE {
+		e.MFE = distance
+		e.MFETime = tickTime
+		e.TimeToMFE = tickTime.Sub(trade.OpenTime)
+		e.DrawdownBeforeMFE = e.MAE
+	}
+
+	if tickTime.Before(trade.CloseTime) {
+		return true
+	}
+
+	e.ExitTick = tick
+	if trade.Side == Buy {
+		e.ExitSlippage = tick.Bid - trade.ClosePrice
+	} else {
+		e.ExitSlippage = trade.ClosePrice - tick.Ask
+	}
+	e.SpreadCost += (tick.Ask - tick.Bid) / 2
+
+	return false
+}
+
+// touchLevels records when the stop loss and take profit were first touched, on the closing side
+func (e *Excursion) touchLevels(tickTime time.Time, tick *tickdata.TickData) {
+	trade := e.Trade
+	price := tick.Bid
+	if trade.Side == Sell {
+		price = tick.Ask
+	}
+
+	if e.StopLossTime.IsZero() && crosses(trade.Side, StopLoss, price, trade.StopLoss) {
+		e.StopLossTime = tickTime
+	}
+	if e.TakeProfitTime.IsZero() && crosses(trade.Side, TakeProfit, price, trade.TakeProfit) {
+		e.TakeProfitTime = tickTime
+	}
+}
@@ -0,0 +1,126 @@
+package analysis
+
+import (
+	"github.com/edward-yakop/go-duka/api/datafeed"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	_ "time/tzdata" // Ensure that custom timezone is included
+)
+
+// writeHour writes GBPJPY ticks at 15:00 UTC, 17:00 EET, a second apart with the given bids and a 2 points spread
+func writeHour(t *testing.T, mirror string, bids ...float64) time.Time {
+	dayHour := time.Date(2020, time.November, 3, 15, 0, 0, 0, time.UTC)
+	content := make([]*tickdata.TickData, 0, len(bids))
+	for i, bid := range bids {
+		content = append(content, bi5test.Tick("GBPJPY", dayHour.Add(time.Duration(i)*time.Second), bid+0.002, bid))
+	}
+	bi5test.WriteMirror(t, mirror, "GBPJPY", dayHour, bi5test.Encode(t, dayHour, 1000, content...))
+
+	return dayHour
+}
+
+func TestAnalyze_Buy(t *testing.T) {
+	mirror := t.TempDir()
+	dayHour := writeHour(t, mirror, 136.300, 136.280, 136.250, 136.400, 136.450, 136.420, 136.500)
+
+	eet, _ := time.LoadLocation("EET")
+	trade := Trade{
+		Side:       Buy,
+		OpenTime:   time.Date(2020, time.November, 3, 17, 0, 0, 0, eet),
+		OpenPrice:  136.303,
+		CloseTime:  dayHour.Add(5 * time.Second),
+		ClosePrice: 136.418,
+	}
+	im := instrument.GetMetadata("GBPJPY")
+	e, err := Analyze(im, trade, t.TempDir(), bi5test.FileSource(t, mirror))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, 6, e.Ticks)
+	assert.Equal(t, 1, Points(im, e.EntrySlippage))
+	assert.Equal(t, 2, Points(im, e.ExitSlippage))
+	assert.Equal(t, -53, Points(im, e.MAE))
+	assert.Equal(t, 147, Points(im, e.MFE))
+	assert.Equal(t, -53, Points(im, e.DrawdownBeforeMFE))
+	assert.Equal(t, 4*time.Second, e.TimeToMFE)
+	assert.Equal(t, eet, e.MFETime.Location())
+	assert.Equal(t, dayHour.Add(4*time.Second), e.MFETime.UTC())
+	assert.Equal(t, 2, Points(im, e.SpreadCost))
+	assert.Equal(t, dayHour.Add(5*time.Second), e.ExitTick.UTC())
+}
+
+func TestAnalyze_Sell(t *testing.T) {
+	mirror := t.TempDir()
+	dayHour := writeHour(t, mirror, 136.300, 136.400, 136.200, 136.350)
+
+	trade := Trade{
+		Side:       Sell,
+		OpenTime:   dayHour,
+		OpenPrice:  136.300,
+		CloseTime:  dayHour.Add(2500 * time.Millisecond),
+		ClosePrice: 136.352,
+	}
+	im := instrument.GetMetadata("GBPJPY")
+	e, err := Analyze(im, trade, t.TempDir(), bi5test.FileSource(t, mirror))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// Sell trades are followed on the ask
+	assert.Equal(t, 4, e.Ticks)
+	assert.Equal(t, 0, Points(im, e.EntrySlippage))
+	assert.Equal(t, 0, Points(im, e.ExitSlippage))
+	assert.Equal(t, -102, Points(im, e.MAE))
+	assert.Equal(t, 98, Points(im, e.MFE))
+	assert.Equal(t, -102, Points(im, e.DrawdownBeforeMFE))
+	assert.Equal(t, 2*time.Second, e.TimeToMFE)
+}
+
+func TestAnalyze_Errors(t *testing.T) {
+	im := instrument.GetMetadata("GBPJPY")
+	at := time.Date(2020, time.November, 3, 15, 0, 0, 0, time.UTC)
+
+	_, err := Analyze(im, Trade{OpenTime: at, CloseTime: at.Add(-time.Hour)}, t.TempDir())
+	assert.Error(t, err)
+
+	_, err = Analyze(im, Trade{OpenTime: at, CloseTime: at.Add(time.Hour)}, t.TempDir(), datafeed.WithOffline())
+	var notCached *datafeed.ErrNotCached
+	assert.ErrorAs(t, err, &notCached)
+}
+
+func TestAnalyzeAll_OverlappingTradesAndLevels(t *testing.T) {
+	mirror := t.TempDir()
+	dayHour := writeHour(t, mirror, 136.300, 136.280, 136.250, 136.400, 136.450, 136.420, 136.500)
+	later := dayHour.Add(26 * time.Hour)
+	bi5test.WriteMirror(t, mirror, "GBPJPY", later, bi5test.Encode(t, later, 1000,
+		bi5test.Tick("GBPJPY", later, 137.002, 137.000),
+		bi5test.Tick("GBPJPY", later.Add(time.Second), 137.102, 137.100),
+	))
+
+	trades := []Trade{
+		{Side: Buy, OpenTime: later, OpenPrice: 137.002, CloseTime: later, ClosePrice: 137.000},
+		{Side: Sell, OpenTime: dayHour.Add(2 * time.Second), OpenPrice: 136.250, CloseTime: dayHour.Add(6 * time.Second), ClosePrice: 136.502, StopLoss: 136.420},
+		{Side: Buy, OpenTime: dayHour, OpenPrice: 136.302, CloseTime: dayHour.Add(5 * time.Second), ClosePrice: 136.420, TakeProfit: 136.400, StopLoss: 136.200},
+	}
+	im := instrument.GetMetadata("GBPJPY")
+	excursions, err := AnalyzeAll(im, trades, t.TempDir(), bi5test.FileSource(t, mirror))
+	if !assert.NoError(t, err) || !assert.Len(t, excursions, 3) {
+		t.FailNow()
+	}
+
+	for i, e := range excursions {
+		assert.Equal(t, trades[i], e.Trade)
+		assert.NotNil(t, e.ExitTick)
+	}
+	assert.Equal(t, 1, excursions[0].Ticks)
+	assert.Equal(t, dayHour.Add(4*time.Second), excursions[1].StopLossTime.UTC())
+	assert.True(t, excursions[1].TakeProfitTime.IsZero())
+	assert.Equal(t, dayHour.Add(3*time.Second), excursions[2].TakeProfitTime.UTC())
+	assert.True(t, excursions[2].StopLossTime.IsZero())
+	assert.Equal(t, 148, Points(im, excursions[2].MFE))
+}
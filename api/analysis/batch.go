@@ -0,0 +1,97 @@
+package analysis
+
+import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/api/tickdata/stream"
+	"github.com/pkg/errors"
+	"sort"
+	"time"
+)
+
+// AnalyzeAll is AnalyzeAllContext without cancellation
+func AnalyzeAll(instrument *instrument.Metadata, trades []Trade, downloadFolderPath string, opts ...datafeed.Option) ([]Excursion, error) {
+	return AnalyzeAllContext(context.Background(), instrument, trades, downloadFolderPath, opts...)
+}
+
+// AnalyzeAllContext analyzes the trades of an instrument, the excursions are in the order of the trades.
+// Trades overlapping, or within the same hour, are streamed together so their hours are decoded once.
+// A trade without exit tick has a nil ExitTick instead of failing the batch, stream errors stop the batch.
+func AnalyzeAllContext(ctx context.Context, instrument *instrument.Metadata, trades []Trade, downloadFolderPath string, opts ...datafeed.Option) ([]Excursion, error) {
+	r := make([]Excursion, len(trades))
+	order := make([]int, 0, len(trades))
+	for i, trade := range trades {
+		r[i].Trade = trade
+		if trade.CloseTime.Before(trade.OpenTime) {
+			return nil, errors.Errorf("trade [%d] closes at [%s] before it opens at [%s]", i, trade.CloseTime, trade.OpenTime)
+		}
+		order = append(order, i)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return trades[order[i]].OpenTime.Before(trades[order[j]].OpenTime)
+	})
+
+	for len(order) > 0 {
+		// Trades opening before the group closes, or within its last hour, join the group
+		n := 1
+		groupEnd := trades[order[0]].CloseTime
+		for ; n < len(order); n++ {
+			trade := trades[order[n]]
+			if trade.OpenTime.After(groupEnd.Add(time.Hour)) {
+				break
+			}
+			if trade.CloseTime.After(groupEnd) {
+				groupEnd = trade.CloseTime
+			}
+		}
+
+		group := make([]*Excursion, 0, n)
+		for _, i := range order[:n] {
+			group = append(group, &r[i])
+		}
+		if err := analyzeGroup(ctx, instrument, group, groupEnd, downloadFolderPath, opts); err != nil {
+			return r, errors.Wrapf(err, "failed to analyze [%s] trades opened from [%s]", instrument.Code(), group[0].Trade.OpenTime)
+		}
+		order = order[n:]
+	}
+
+	return r, nil
+}
+
+// analyzeGroup streams the ticks of trades sorted by open time until all of them reached their exit tick
+func analyzeGroup(ctx context.Context, instrument *instrument.Metadata, group []*Excursion, groupEnd time.Time, downloadFolderPath string, opts []datafeed.Option) error {
+	var (
+		pending   = group
+		active    []*Excursion
+		streamErr error
+	)
+	s := stream.New(instrument, group[0].Trade.OpenTime, groupEnd.Add(ExitLookahead), downloadFolderPath, opts...)
+	err := s.EachTickContext(ctx, func(tickTime time.Time, tick *tickdata.TickData, err error) bool {
+		if err != nil {
+			streamErr = err
+			return false
+		}
+
+		for len(pending) > 0 && !pending[0].Trade.OpenTime.After(tickTime) {
+			active = append(active, pending[0])
+			pending = pending[1:]
+		}
+
+		remaining := active[:0]
+		for _, e := range active {
+			if e.add(tickTime.In(e.Trade.OpenTime.Location()), tick) {
+				remaining = append(remaining, e)
+			}
+		}
+		active = remaining
+
+		return len(pending) > 0 || len(active) > 0
+	})
+	if err != nil {
+		return err
+	}
+
+	return streamErr
+}
@@ -0,0 +1,246 @@
+package analysis
+
+import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/api/tickdata/stream"
+	"github.com/pkg/errors"
+	"math"
+	"time"
+)
+
+type LevelKind int
+
+const (
+	StopLoss LevelKind = iota
+	TakeProfit
+	TrailingStop
+)
+
+func (k LevelKind) String() string {
+	switch k {
+	case TakeProfit:
+		return "tp"
+	case TrailingStop:
+		return "trailing"
+	default:
+		return "sl"
+	}
+}
+
+// Level of a trade exit
+type Level struct {
+	Name  string
+	Kind  LevelKind
+	Price float64
+}
+
+// Trailing stop following the exit price at Distance once the trade is Activation in profit, like MT4.
+// The stop only moves in favour of the trade, by at least Step.
+type Trailing struct {
+	Distance   float64
+	Activation float64
+	Step       float64
+}
+
+// LevelQuery of a trade opened at Entry, looked up until Until
+type LevelQuery struct {
+	Side  Side
+	Entry time.Time
+	Until time.Time
+	// EntryPrice the trailing stop activation is measured from, the entry tick price when 0
+	EntryPrice float64
+	Levels     []Level
+	Trailing   *Trailing
+	// SpreadMarkup widens the spread, the bid is lowered and the ask is raised by half of it
+	SpreadMarkup float64
+	// AllLevels keeps searching after the first touch until all levels are touched,
+	// by default the search stops at the first touch like the trade would be closed
+	AllLevels bool
+}
+
+// Touch of a level, Tick is nil if the level wasn't touched
+type Touch struct {
+	Level Level
+	Tick  *tickdata.TickData
+	// Time in the location of the entry
+	Time time.Time
+	// Price on the exit side, including the markup
+	Price float64
+}
+
+func (t Touch) IsTouched() bool {
+	return t.Tick != nil
+}
+
+// LevelResult has a touch per level, in the query order. Trailing is the trailing stop touch.
+type LevelResult struct {
+	EntryTick *tickdata.TickData
+	Touches   []Touch
+	Trailing  Touch
+	// First is the earliest touch, the level listed first wins ties, nil if none was touched
+	First *Touch
+}
+
+// Touches is TouchesContext without cancellation
+func Touches(instrument *instrument.Metadata, q LevelQuery, downloadFolderPath string, opts ...datafeed.Option) (LevelResult, error) {
+	return TouchesContext(context.Background(), instrument, q, downloadFolderPath, opts...)
+}
+
+// TouchesContext streams the ticks from the entry and returns the first tick crossing each level.
+// Buy trades exit on the bid and sell trades on the ask. The stream stops as soon as the answer is known.
+func TouchesContext(ctx context.Context, instrument *instrument.Metadata, q LevelQuery, downloadFolderPath string, opts ...datafeed.Option) (LevelResult, error) {
+	r := LevelResult{Touches: make([]Touch, len(q.Levels))}
+	for i, level := range q.Levels {
+		r.Touches[i].Level = level
+		if level.Kind == TrailingStop {
+			return r, errors.Errorf("level [%s] is a trailing stop, use LevelQuery.Trailing", level.Name)
+		}
+	}
+	if q.Until.IsZero() || q.Until.Before(q.Entry) {
+		return r, errors.Errorf("invalid until [%s], it must be after the entry [%s]", q.Until, q.Entry)
+	}
+	r.Trailing.Level = Level{Name: "trailing", Kind: TrailingStop}
+
+	var (
+		streamErr error
+		trailing  trailingStop
+		isTouched bool
+	)
+	loc := q.Entry.Location()
+	s := stream.New(instrument, q.Entry, q.Until, downloadFolderPath, opts...)
+	err := s.EachTickContext(ctx, func(tickTime time.Time, tick *tickdata.TickData, err error) bool {
+		if err != nil {
+			streamErr = err
+			return false
+		}
+
+		bid := tick.Bid - q.SpreadMarkup/2
+		ask := tick.Ask + q.SpreadMarkup/2
+		exitPrice := bid
+		if q.Side == Sell {
+			exitPrice = ask
+		}
+		if r.EntryTick == nil {
+			r.EntryTick = tick
+			entryPrice := q.EntryPrice
+			if entryPrice == 0 {
+				entryPrice = ask
+				if q.Side == Sell {
+					entryPrice = bid
+				}
+			}
+			trailing = trailingStop{side: q.Side, entryPrice: entryPrice, stop: math.NaN()}
+		}
+
+		touch := func(t *Touch) {
+			t.Tick = tick
+			t.Time = tickTime.In(loc)
+			t.Price = exitPrice
+			isTouched = true
+		}
+
+		for i := range r.Touches {
+			t := &r.Touches[i]
+			if !t.IsTouched() && crosses(q.Side, t.Level.Kind, exitPrice, t.Level.Price) {
+				touch(t)
+			}
+		}
+		if q.Trailing != nil && !r.Trailing.IsTouched() {
+			if trailing.isHit(exitPrice) {
+				r.Trailing.Level.Price = trailing.stop
+				touch(&r.Trailing)
+			} else {
+				trailing.follow(*q.Trailing, exitPrice)
+			}
+		}
+
+		if !q.AllLevels {
+			return !isTouched
+		}
+		return !r.isAllTouched(q.Trailing != nil)
+	})
+	if err == nil {
+		err = streamErr
+	}
+	if err != nil {
+		return r, errors.Wrapf(err, "failed to look up [%s] levels from [%s]", instrument.Code(), q.Entry)
+	}
+
+	r.First = r.earliest()
+	return r, nil
+}
+
+// earliest touch, the level listed first wins ties and the trailing stop comes last
+func (r *LevelResult) earliest() *Touch {
+	var first *Touch
+	for i := range r.Touches {
+		if t := &r.Touches[i]; t.IsTouched() && (first == nil || t.Tick.Timestamp < first.Tick.Timestamp) {
+			first = t
+		}
+	}
+	if r.Trailing.IsTouched() && (first == nil || r.Trailing.Tick.Timestamp < first.Tick.Timestamp) {
+		first = &r.Trailing
+	}
+
+	return first
+}
+
+func (r *LevelResult) isAllTouched(hasTrailing bool) bool {
+	for _, t := range r.Touches {
+		if !t.IsTouched() {
+			return false
+		}
+	}
+
+	return !hasTrailing || r.Trailing.IsTouched()
+}
+
+// crosses returns whether the exit price reached the level
+func crosses(side Side, kind LevelKind, exitPrice float64, level float64) bool {
+	if level <= 0 {
+		return false
+	}
+
+	isLoss := kind != TakeProfit
+	if side == Buy {
+		return (isLoss && exitPrice <= level) || (!isLoss && exitPrice >= level)
+	}
+
+	return (isLoss && exitPrice >= level) || (!isLoss && exitPrice <= level)
+}
+
+// trailingStop state, stop is NaN until activated
+type trailingStop struct {
+	side       Side
+	entryPrice float64
+	stop       float64
+}
+
+func (t *trailingStop) isHit(exitPrice float64) bool {
+	return !math.IsNaN(t.stop) && crosses(t.side, TrailingStop, exitPrice, t.stop)
+}
+
+func (t *trailingStop) follow(rule Trailing, exitPrice float64) {
+	profit := exitPrice - t.entryPrice
+	stop := exitPrice - rule.Distance
+	if t.side == Sell {
+		profit = -profit
+		stop = exitPrice + rule.Distance
+	}
+	if profit < rule.Activation {
+		return
+	}
+
+	if math.IsNaN(t.stop) {
+		t.stop = stop
+		return
+	}
+	if t.side == Buy && stop-t.stop >= rule.Step && stop > t.stop {
+		t.stop = stop
+	} else if t.side == Sell && t.stop-stop >= rule.Step && stop < t.stop {
+		t.stop = stop
+	}
+}
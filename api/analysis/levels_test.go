@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/internal/bi5"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTouches_Buy(t *testing.T) {
+	mirror := t.TempDir()
+	dayHour := writeHour(t, mirror, 136.300, 136.280, 136.250, 136.400, 136.450, 136.420, 136.500)
+
+	folder := t.TempDir()
+	im := instrument.GetMetadata("GBPJPY")
+	q := LevelQuery{
+		Side:  Buy,
+		Entry: dayHour,
+		Until: dayHour.Add(2 * time.Hour),
+		Levels: []Level{
+			{Name: "sl", Kind: StopLoss, Price: 136.250},
+			{Name: "tp", Kind: TakeProfit, Price: 136.440},
+		},
+	}
+	r, err := Touches(im, q, folder, bi5test.FileSource(t, mirror))
+	if !assert.NoError(t, err) || !assert.NotNil(t, r.First) {
+		t.FailNow()
+	}
+
+	// Buy trades exit on the bid, the search stops at the first touch
+	assert.Equal(t, "sl", r.First.Level.Name)
+	assert.Equal(t, dayHour.Add(2*time.Second), r.First.Time.UTC())
+	assert.Equal(t, 136.250, r.First.Price)
+	assert.False(t, r.Touches[1].IsTouched())
+	next, _ := filepath.Glob(filepath.Join(filepath.Dir(bi5.BiFilePathTime(folder, "GBPJPY", dayHour)), "16h*"))
+	assert.Empty(t, next, "the next hour isn't downloaded")
+
+	q.AllLevels = true
+	r, err = Touches(im, q, folder, bi5test.FileSource(t, mirror))
+	if assert.NoError(t, err) && assert.True(t, r.Touches[1].IsTouched()) {
+		assert.Equal(t, dayHour.Add(4*time.Second), r.Touches[1].Time.UTC())
+		assert.Equal(t, "sl", r.First.Level.Name)
+	}
+}
+
+func TestTouches_SellWithMarkup(t *testing.T) {
+	mirror := t.TempDir()
+	dayHour := writeHour(t, mirror, 136.300, 136.280, 136.250, 136.400)
+
+	q := LevelQuery{
+		Side:  Sell,
+		Entry: dayHour,
+		Until: dayHour.Add(time.Hour),
+		Levels: []Level{
+			{Name: "tp", Kind: TakeProfit, Price: 136.254},
+		},
+	}
+	im := instrument.GetMetadata("GBPJPY")
+	r, err := Touches(im, q, t.TempDir(), bi5test.FileSource(t, mirror))
+	if assert.NoError(t, err) && assert.NotNil(t, r.First) {
+		// Sell trades exit on the ask, 136.252
+		assert.Equal(t, dayHour.Add(2*time.Second), r.First.Time.UTC())
+	}
+
+	q.SpreadMarkup = 0.006
+	r, err = Touches(im, q, t.TempDir(), bi5test.FileSource(t, mirror))
+	assert.NoError(t, err)
+	assert.Nil(t, r.First, "the ask is 136.255 with the markup")
+}
+
+func TestTouches_Trailing(t *testing.T) {
+	mirror := t.TempDir()
+	dayHour := writeHour(t, mirror, 136.300, 136.350, 136.420, 136.390, 136.360)
+
+	q := LevelQuery{
+		Side:       Buy,
+		Entry:      dayHour,
+		Until:      dayHour.Add(time.Hour),
+		EntryPrice: 136.300,
+		Levels:     []Level{{Name: "sl", Kind: StopLoss, Price: 136.200}},
+		Trailing:   &Trailing{Distance: 0.050, Activation: 0.040},
+	}
+	r, err := Touches(instrument.GetMetadata("GBPJPY"), q, t.TempDir(), bi5test.FileSource(t, mirror))
+	if assert.NoError(t, err) && assert.NotNil(t, r.First) {
+		// Activated at 136.350 with a stop at 136.300, moved to 136.370 and hit by 136.360
+		assert.Equal(t, TrailingStop, r.First.Level.Kind)
+		assert.InDelta(t, 136.370, r.First.Level.Price, 1e-9)
+		assert.Equal(t, dayHour.Add(4*time.Second), r.First.Time.UTC())
+		assert.False(t, r.Touches[0].IsTouched())
+	}
+
+	q.Until = time.Time{}
+	_, err = Touches(instrument.GetMetadata("GBPJPY"), q, t.TempDir())
+	assert.Error(t, err)
+}
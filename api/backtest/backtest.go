@@ -0,0 +1,119 @@
+// Package backtest runs a strategy tick by tick against a simulated broker
+package backtest
+
+import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/bars"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/pkg/errors"
+	"iter"
+	"math"
+	"time"
+)
+
+// Strategy receives the ticks and the completed bars, orders are sent through the broker.
+// OnBar is called before OnTick with the tick opening the next bar, which is the broker current tick.
+type Strategy interface {
+	OnTick(b *Broker, tick *tickdata.TickData)
+	OnBar(b *Broker, bar bars.Bar)
+}
+
+// Config of the simulated broker
+type Config struct {
+	// Balance at the start
+	Balance float64
+	// Commission per volume unit, charged when opening and when closing a position
+	Commission float64
+	// Slippage in price units, worsening the market and stop fills
+	Slippage float64
+	// Timeframe of the bars passed to OnBar, the equity curve has a point per bar (default H1)
+	Timeframe *bars.Timeframe
+}
+
+// EquityPoint of the equity curve, at the close of a bar. Drawdown is from the peak equity of all ticks so far.
+type EquityPoint struct {
+	Time     time.Time
+	Balance  float64
+	Equity   float64
+	Drawdown float64
+}
+
+// Result of a backtest, the positions still open at the end are closed on the last tick.
+// MaxDrawdown is measured on every tick.
+type Result struct {
+	Trades      []Trade
+	Equity      []EquityPoint
+	Balance     float64
+	MaxDrawdown float64
+	Ticks       int
+}
+
+// Run is RunContext without cancellation
+func Run(strategy Strategy, ticks iter.Seq2[*tickdata.TickData, error], config Config) (Result, error) {
+	return RunContext(context.Background(), strategy, ticks, config)
+}
+
+// RunContext feeds the ticks to the broker and the strategy, like stream.All() or ticks.All().
+// Returns the result so far with the first tick error or ctx.Err().
+func RunContext(ctx context.Context, strategy Strategy, ticks iter.Seq2[*tickdata.TickData, error], config Config) (Result, error) {
+	timeframe := bars.H1
+	if config.Timeframe != nil {
+		timeframe = *config.Timeframe
+	}
+
+	var (
+		r       Result
+		runErr  error
+		peak    = config.Balance
+		broker  = newBroker(config)
+		builder = bars.NewBuilder(timeframe)
+	)
+	measure := func() EquityPoint {
+		equity := broker.Equity()
+		peak = math.Max(peak, equity)
+		point := EquityPoint{Time: broker.Time(), Balance: broker.Balance(), Equity: equity, Drawdown: drawdown(peak, equity)}
+		r.MaxDrawdown = math.Max(r.MaxDrawdown, point.Drawdown)
+
+		return point
+	}
+
+	for tick, err := range ticks {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			runErr = errors.Wrap(err, "backtest interrupted")
+			break
+		}
+
+		r.Ticks++
+		bar, isCompleted := builder.Add(tick)
+		if isCompleted {
+			// The bar closed on the previous tick
+			point := measure()
+			point.Time = timeframe.NextBarStart(bar.Time)
+			r.Equity = append(r.Equity, point)
+		}
+		broker.update(tick.UTC(), tick)
+		if isCompleted {
+			strategy.OnBar(broker, bar)
+		}
+		broker.process()
+		measure()
+		strategy.OnTick(broker, tick)
+	}
+
+	if broker.Tick() != nil {
+		broker.closeAll()
+		r.Equity = append(r.Equity, measure())
+	}
+
+	r.Trades = broker.Trades()
+	r.Balance = broker.Balance()
+	return r, runErr
+}
+
+// drawdown of the equity from its peak
+func drawdown(peak, equity float64) float64 {
+	return math.Max(0, peak-equity)
+}
@@ -0,0 +1,172 @@
+package backtest
+
+import (
+	"github.com/edward-yakop/go-duka/api/bars"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+
+// strategyFunc adapts functions to Strategy
+type strategyFunc struct {
+	onTick func(b *Broker, tick *tickdata.TickData)
+	onBar  func(b *Broker, bar bars.Bar)
+}
+
+func (s strategyFunc) OnTick(b *Broker, tick *tickdata.TickData) {
+	if s.onTick != nil {
+		s.onTick(b, tick)
+	}
+}
+
+func (s strategyFunc) OnBar(b *Broker, bar bars.Bar) {
+	if s.onBar != nil {
+		s.onBar(b, bar)
+	}
+}
+
+// minuteTicks returns a tick per minute with the given bids and a 2 points spread
+func minuteTicks(bids ...float64) func(yield func(*tickdata.TickData, error) bool) {
+	return func(yield func(*tickdata.TickData, error) bool) {
+		for i, bid := range bids {
+			if !yield(bi5test.Tick("EURUSD", start.Add(time.Duration(i)*time.Minute), bid+0.0002, bid), nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestRun_MarketOrderTakeProfit(t *testing.T) {
+	strategy := strategyFunc{onTick: func(b *Broker, tick *tickdata.TickData) {
+		if len(b.Positions()) == 0 && len(b.Trades()) == 0 {
+			b.Buy(1000, 1.2150, 1.2230)
+		}
+	}}
+
+	m1 := bars.M1
+	r, err := Run(strategy, minuteTicks(1.2200, 1.2210, 1.2230, 1.2240), Config{
+		Balance:    1000,
+		Commission: 0.00002,
+		Slippage:   0.0001,
+		Timeframe:  &m1,
+	})
+	if !assert.NoError(t, err) || !assert.Len(t, r.Trades, 1) {
+		t.FailNow()
+	}
+
+	trade := r.Trades[0]
+	assert.Equal(t, ClosedByTakeProfit, trade.Reason)
+	assert.InDelta(t, 1.2203, trade.OpenPrice, 1e-9, "ask with slippage")
+	assert.InDelta(t, 1.2230, trade.ClosePrice, 1e-9)
+	assert.Equal(t, start.Add(2*time.Minute), trade.CloseTime)
+	assert.InDelta(t, 0.04, trade.Commission, 1e-9)
+	assert.InDelta(t, 2.7-0.04, trade.Profit, 1e-9)
+	assert.InDelta(t, 1000+2.7-0.04, r.Balance, 1e-9)
+	assert.Equal(t, 4, r.Ticks)
+
+	// A point per completed minute bar and one at the end
+	if assert.Len(t, r.Equity, 4) {
+		assert.Equal(t, start.Add(time.Minute), r.Equity[0].Time)
+		assert.InDelta(t, 1000-0.02-0.3, r.Equity[0].Equity, 1e-9)
+		assert.InDelta(t, 0.32, r.MaxDrawdown, 1e-9)
+	}
+}
+
+func TestRun_MarketOrderOnBar(t *testing.T) {
+	var barTime, tickTime time.Time
+	strategy := strategyFunc{onBar: func(b *Broker, bar bars.Bar) {
+		if len(b.Positions()) == 0 && len(b.Trades()) == 0 {
+			barTime, tickTime = bar.Time, b.Time()
+			b.Buy(1000, 0, 0)
+		}
+	}}
+
+	m1 := bars.M1
+	r, err := Run(strategy, minuteTicks(1.2200, 1.2210, 1.2230), Config{Timeframe: &m1})
+	if !assert.NoError(t, err) || !assert.Len(t, r.Trades, 1) {
+		t.FailNow()
+	}
+
+	// Filled on the ask of the tick opening the next bar, not the close of the completed one
+	assert.Equal(t, start, barTime)
+	assert.Equal(t, start.Add(time.Minute), tickTime)
+	assert.InDelta(t, 1.2212, r.Trades[0].OpenPrice, 1e-9)
+	assert.Equal(t, start.Add(time.Minute), r.Trades[0].OpenTime)
+}
+
+func TestRun_MaxDrawdownWithinBar(t *testing.T) {
+	strategy := strategyFunc{onTick: func(b *Broker, tick *tickdata.TickData) {
+		if len(b.Positions()) == 0 && len(b.Trades()) == 0 {
+			b.Buy(1000, 0, 0)
+		}
+	}}
+
+	// A single hour bar, the dip to 1.2150 recovers before the bar closes
+	r, err := Run(strategy, minuteTicks(1.2200, 1.2150, 1.2210), Config{Balance: 100})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.Len(t, r.Equity, 1, "only the end point")
+	assert.InDelta(t, 0.8, r.Equity[0].Equity-100, 1e-9)
+	assert.InDelta(t, 5.2, r.MaxDrawdown, 1e-9, "bought at 1.2202, the bid dipped to 1.2150")
+}
+
+func TestRun_PendingOrdersAndStopLoss(t *testing.T) {
+	var limitID, stopID int
+	strategy := strategyFunc{onBar: func(b *Broker, bar bars.Bar) {
+		if limitID == 0 {
+			limitID = b.SellLimit(1.2220, 1000, 1.2250, 0)
+			stopID = b.BuyStop(1.2300, 1000, 0, 0)
+		}
+	}}
+
+	m1 := bars.M1
+	r, err := Run(strategy, minuteTicks(1.2200, 1.2210, 1.2225, 1.2260, 1.2240), Config{Timeframe: &m1})
+	if !assert.NoError(t, err) || !assert.Len(t, r.Trades, 1) {
+		t.FailNow()
+	}
+
+	// The sell limit is filled on the bid at 1.2225, stopped out on the ask at 1.2262
+	sell := r.Trades[0]
+	assert.Equal(t, limitID, sell.PositionID)
+	assert.Equal(t, Sell, sell.Side)
+	assert.InDelta(t, 1.2225, sell.OpenPrice, 1e-9)
+	assert.Equal(t, ClosedByStopLoss, sell.Reason)
+	assert.InDelta(t, 1.2262, sell.ClosePrice, 1e-9)
+	assert.InDelta(t, -3.7, sell.Profit, 1e-9)
+
+	// The buy stop never triggers, it is cancelled at the end
+	assert.NotEqual(t, stopID, sell.PositionID)
+}
+
+func TestRun_CloseAtEndAndError(t *testing.T) {
+	strategy := strategyFunc{onTick: func(b *Broker, tick *tickdata.TickData) {
+		if len(b.Positions()) == 0 && len(b.Trades()) == 0 {
+			b.Sell(1000, 0, 0)
+		}
+	}}
+
+	failure := errors.New("decode failed")
+	ticks := func(yield func(*tickdata.TickData, error) bool) {
+		for tick, err := range minuteTicks(1.2200, 1.2190) {
+			if !yield(tick, err) {
+				return
+			}
+		}
+		yield(nil, failure)
+	}
+
+	r, err := Run(strategy, ticks, Config{Balance: 100})
+	assert.ErrorIs(t, err, failure)
+	if assert.Len(t, r.Trades, 1) {
+		assert.Equal(t, ClosedAtEnd, r.Trades[0].Reason)
+		assert.InDelta(t, 0.8, r.Trades[0].Profit, 1e-9)
+	}
+	assert.InDelta(t, 100.8, r.Balance, 1e-9)
+}
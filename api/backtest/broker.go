@@ -0,0 +1,354 @@
+package backtest
+
+import (
+	"github.com/edward-yakop/go-duka/api/analysis"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"time"
+)
+
+type OrderType int
+
+const (
+	Market OrderType = iota
+	Limit
+	Stop
+)
+
+func (t OrderType) String() string {
+	switch t {
+	case Limit:
+		return "limit"
+	case Stop:
+		return "stop"
+	default:
+		return "market"
+	}
+}
+
+// Order pending until the price reaches Price. Buy orders trigger on the ask, sell orders on the bid.
+type Order struct {
+	ID         int
+	Side       analysis.Side
+	Type       OrderType
+	Volume     float64
+	Price      float64
+	StopLoss   float64
+	TakeProfit float64
+	Created    time.Time
+}
+
+// Position opened by a filled order, StopLoss and TakeProfit are 0 when not set
+type Position struct {
+	ID         int
+	Side       analysis.Side
+	Volume     float64
+	OpenTime   time.Time
+	OpenPrice  float64
+	StopLoss   float64
+	TakeProfit float64
+	Commission float64
+}
+
+// profit of the position closed at price, without commission
+func (p Position) profit(price float64) float64 {
+	if p.Side == Sell {
+		return (p.OpenPrice - price) * p.Volume
+	}
+
+	return (price - p.OpenPrice) * p.Volume
+}
+
+// CloseReason tells why a position was closed
+type CloseReason string
+
+const (
+	ClosedByStrategy   CloseReason = "close"
+	ClosedByStopLoss   CloseReason = "sl"
+	ClosedByTakeProfit CloseReason = "tp"
+	ClosedAtEnd        CloseReason = "end"
+)
+
+// Trade is a closed position. Profit is in the quote currency and includes the commission.
+type Trade struct {
+	PositionID int
+	Side       analysis.Side
+	Volume     float64
+	OpenTime   time.Time
+	OpenPrice  float64
+	CloseTime  time.Time
+	ClosePrice float64
+	Commission float64
+	Profit     float64
+	Reason     CloseReason
+}
+
+const (
+	Buy  = analysis.Buy
+	Sell = analysis.Sell
+)
+
+// Broker simulates the order execution on the ticks. Market orders are filled on the current tick,
+// buy orders on the ask and sell orders on the bid. Slippage worsens the market and stop fills.
+type Broker struct {
+	config    Config
+	tick      *tickdata.TickData
+	time      time.Time
+	nextID    int
+	balance   float64
+	orders    []Order
+	positions []Position
+	trades    []Trade
+}
+
+func newBroker(config Config) *Broker {
+	return &Broker{
+		config:  config,
+		nextID:  1,
+		balance: config.Balance,
+	}
+}
+
+// Time of the current tick
+func (b *Broker) Time() time.Time {
+	return b.time
+}
+
+// Tick is the current tick
+func (b *Broker) Tick() *tickdata.TickData {
+	return b.tick
+}
+
+func (b *Broker) Balance() float64 {
+	return b.balance
+}
+
+// Equity is the balance plus the floating profit of the open positions, closed at the current tick
+func (b *Broker) Equity() float64 {
+	equity := b.balance
+	for _, p := range b.positions {
+		equity += p.profit(b.closePrice(p.Side, false))
+	}
+
+	return equity
+}
+
+// Positions are the open positions
+func (b *Broker) Positions() []Position {
+	return append([]Position(nil), b.positions...)
+}
+
+// Orders are the pending orders
+func (b *Broker) Orders() []Order {
+	return append([]Order(nil), b.orders...)
+}
+
+// Trades are the closed positions
+func (b *Broker) Trades() []Trade {
+	return append([]Trade(nil), b.trades...)
+}
+
+// Buy opens a position at the current ask, returns its ID
+func (b *Broker) Buy(volume, stopLoss, takeProfit float64) int {
+	return b.market(Buy, volume, stopLoss, takeProfit)
+}
+
+// Sell opens a position at the current bid, returns its ID
+func (b *Broker) Sell(volume, stopLoss, takeProfit float64) int {
+	return b.market(Sell, volume, stopLoss, takeProfit)
+}
+
+// BuyLimit fills once the ask is at or below price, returns the order ID
+func (b *Broker) BuyLimit(price, volume, stopLoss, takeProfit float64) int {
+	return b.pending(Buy, Limit, price, volume, stopLoss, takeProfit)
+}
+
+// SellLimit fills once the bid is at or above price, returns the order ID
+func (b *Broker) SellLimit(price, volume, stopLoss, takeProfit float64) int {
+	return b.pending(Sell, Limit, price, volume, stopLoss, takeProfit)
+}
+
+// BuyStop fills once the ask is at or above price, returns the order ID
+func (b *Broker) BuyStop(price, volume, stopLoss, takeProfit float64) int {
+	return b.pending(Buy, Stop, price, volume, stopLoss, takeProfit)
+}
+
+// SellStop fills once the bid is at or below price, returns the order ID
+func (b *Broker) SellStop(price, volume, stopLoss, takeProfit float64) int {
+	return b.pending(Sell, Stop, price, volume, stopLoss, takeProfit)
+}
+
+// Cancel the pending order, returns false if it isn't pending
+func (b *Broker) Cancel(orderID int) bool {
+	for i, o := range b.orders {
+		if o.ID == orderID {
+			b.orders = append(b.orders[:i], b.orders[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Close the position at the current tick, returns false if it isn't open
+func (b *Broker) Close(positionID int) bool {
+	for i, p := range b.positions {
+		if p.ID == positionID {
+			b.close(i, b.closePrice(p.Side, true), ClosedByStrategy)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Modify the stop loss and take profit of the position, returns false if it isn't open
+func (b *Broker) Modify(positionID int, stopLoss, takeProfit float64) bool {
+	for i := range b.positions {
+		if b.positions[i].ID == positionID {
+			b.positions[i].StopLoss = stopLoss
+			b.positions[i].TakeProfit = takeProfit
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *Broker) market(side analysis.Side, volume, stopLoss, takeProfit float64) int {
+	id := b.newID()
+	b.open(Order{ID: id, Side: side, Type: Market, Volume: volume, StopLoss: stopLoss, TakeProfit: takeProfit, Created: b.time},
+		b.openPrice(side, true))
+
+	return id
+}
+
+func (b *Broker) pending(side analysis.Side, orderType OrderType, price, volume, stopLoss, takeProfit float64) int {
+	id := b.newID()
+	b.orders = append(b.orders, Order{
+		ID:         id,
+		Side:       side,
+		Type:       orderType,
+		Volume:     volume,
+		Price:      price,
+		StopLoss:   stopLoss,
+		TakeProfit: takeProfit,
+		Created:    b.time,
+	})
+
+	return id
+}
+
+func (b *Broker) newID() int {
+	id := b.nextID
+	b.nextID++
+
+	return id
+}
+
+// open the position of the order, the position has the ID of its order
+func (b *Broker) open(o Order, price float64) {
+	commission := b.config.Commission * o.Volume
+	b.balance -= commission
+	b.positions = append(b.positions, Position{
+		ID:         o.ID,
+		Side:       o.Side,
+		Volume:     o.Volume,
+		OpenTime:   b.time,
+		OpenPrice:  price,
+		StopLoss:   o.StopLoss,
+		TakeProfit: o.TakeProfit,
+		Commission: commission,
+	})
+}
+
+func (b *Broker) close(i int, price float64, reason CloseReason) {
+	p := b.positions[i]
+	commission := b.config.Commission * p.Volume
+	b.balance += p.profit(price) - commission
+	b.trades = append(b.trades, Trade{
+		PositionID: p.ID,
+		Side:       p.Side,
+		Volume:     p.Volume,
+		OpenTime:   p.OpenTime,
+		OpenPrice:  p.OpenPrice,
+		CloseTime:  b.time,
+		ClosePrice: price,
+		Commission: p.Commission + commission,
+		Profit:     p.profit(price) - p.Commission - commission,
+		Reason:     reason,
+	})
+	b.positions = append(b.positions[:i], b.positions[i+1:]...)
+}
+
+// openPrice is the ask for buy and the bid for sell, worsened by the slippage
+func (b *Broker) openPrice(side analysis.Side, isSlipped bool) float64 {
+	slippage := 0.0
+	if isSlipped {
+		slippage = b.config.Slippage
+	}
+	if side == Sell {
+		return b.tick.Bid - slippage
+	}
+
+	return b.tick.Ask + slippage
+}
+
+// closePrice is the bid for buy and the ask for sell, worsened by the slippage
+func (b *Broker) closePrice(side analysis.Side, isSlipped bool) float64 {
+	slippage := 0.0
+	if isSlipped {
+		slippage = b.config.Slippage
+	}
+	if side == Sell {
+		return b.tick.Ask + slippage
+	}
+
+	return b.tick.Bid - slippage
+}
+
+// update the current tick, before OnBar so that its orders are filled on the tick
+func (b *Broker) update(tickTime time.Time, tick *tickdata.TickData) {
+	b.tick = tick
+	b.time = tickTime
+}
+
+// process fills the pending orders and closes the positions reaching their stop loss or take profit on the current tick
+func (b *Broker) process() {
+	pending := b.orders[:0]
+	for _, o := range b.orders {
+		price := b.openPrice(o.Side, false)
+		isBuy := o.Side == Buy
+		switch {
+		case o.Type == Limit && ((isBuy && price <= o.Price) || (!isBuy && price >= o.Price)):
+			// Limit orders fill at the market price, at least as good as the limit
+			b.open(o, price)
+		case o.Type == Stop && ((isBuy && price >= o.Price) || (!isBuy && price <= o.Price)):
+			b.open(o, b.openPrice(o.Side, true))
+		default:
+			pending = append(pending, o)
+		}
+	}
+	b.orders = pending
+
+	for i := 0; i < len(b.positions); {
+		p := b.positions[i]
+		price := b.closePrice(p.Side, false)
+		isBuy := p.Side == Buy
+		switch {
+		case p.StopLoss > 0 && ((isBuy && price <= p.StopLoss) || (!isBuy && price >= p.StopLoss)):
+			b.close(i, b.closePrice(p.Side, true), ClosedByStopLoss)
+		case p.TakeProfit > 0 && ((isBuy && price >= p.TakeProfit) || (!isBuy && price <= p.TakeProfit)):
+			b.close(i, price, ClosedByTakeProfit)
+		default:
+			i++
+		}
+	}
+}
+
+// closeAll closes the open positions at the last tick and cancels the pending orders
+func (b *Broker) closeAll() {
+	for len(b.positions) > 0 {
+		b.close(0, b.closePrice(b.positions[0].Side, true), ClosedAtEnd)
+	}
+	b.orders = nil
+}
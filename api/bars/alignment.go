@@ -0,0 +1,105 @@
+package bars
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Alignment of bars on the wall clock of Location shifted by Shift, following its daylight saving changes.
+// The zero value aligns bars in UTC.
+type Alignment struct {
+	Location *time.Location
+	Shift    time.Duration
+}
+
+// NewYorkClose aligns bars on 17:00 New York, the FX day close.
+// Daily bars close at 17:00 New York, so there are five of them per week.
+func NewYorkClose() (Alignment, error) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return Alignment{}, errors.Wrap(err, "failed to load New York timezone")
+	}
+
+	return Alignment{Location: newYork, Shift: 7 * time.Hour}, nil
+}
+
+var offsetRegx = regexp.MustCompile(`^(?:UTC|GMT)?([+-])(\d{1,2})(?::?(\d{2}))?$`)
+
+// ParseAlignment from a fixed offset like +02:00 or UTC+2, an IANA zone like Europe/Athens
+// or ny-close for NewYorkClose. Blank means UTC.
+func ParseAlignment(s string) (Alignment, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "", "utc", "gmt":
+		return Alignment{}, nil
+	case "ny-close", "nyclose":
+		return NewYorkClose()
+	}
+
+	if ss := offsetRegx.FindStringSubmatch(strings.ToUpper(s)); ss != nil {
+		hours, _ := strconv.Atoi(ss[2])
+		minutes, _ := strconv.Atoi("0" + ss[3])
+		offset := hours*3600 + minutes*60
+		if hours > 14 || minutes > 59 {
+			return Alignment{}, errors.Errorf("invalid offset [%s]", s)
+		}
+		if ss[1] == "-" {
+			offset = -offset
+		}
+
+		return Alignment{Location: time.FixedZone(s, offset)}, nil
+	}
+
+	location, err := time.LoadLocation(s)
+	if err != nil {
+		return Alignment{}, errors.Wrapf(err, "invalid timezone [%s]", s)
+	}
+
+	return Alignment{Location: location}, nil
+}
+
+func (a Alignment) location() *time.Location {
+	if a.Location == nil {
+		return time.UTC
+	}
+
+	return a.Location
+}
+
+func (a Alignment) String() string {
+	if a.Shift == 0 {
+		return a.location().String()
+	}
+
+	return a.location().String() + "+" + a.Shift.String()
+}
+
+// WallClock returns the shifted wall clock of t as an UTC time, like the naive server time of a MT4 broker
+func (a Alignment) WallClock(t time.Time) time.Time {
+	return a.toWall(t)
+}
+
+// FromWallClock returns the time of the shifted wall clock given as an UTC time, the inverse of WallClock
+func (a Alignment) FromWallClock(wall time.Time) time.Time {
+	return a.fromWall(wall)
+}
+
+// toWall returns the shifted wall clock of t as an UTC time
+func (a Alignment) toWall(t time.Time) time.Time {
+	local := t.In(a.location())
+	y, m, d := local.Date()
+
+	return time.Date(y, m, d, local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC).Add(a.Shift)
+}
+
+// fromWall returns the time of the shifted wall clock
+func (a Alignment) fromWall(wall time.Time) time.Time {
+	wall = wall.Add(-a.Shift)
+	y, m, d := wall.Date()
+
+	return time.Date(y, m, d, wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), a.location())
+}
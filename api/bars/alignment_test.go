@@ -0,0 +1,108 @@
+package bars
+
+import (
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	_ "time/tzdata" // Ensure that custom timezone is included
+)
+
+func TestParseAlignment(t *testing.T) {
+	a, err := ParseAlignment("")
+	assert.NoError(t, err)
+	assert.Equal(t, Alignment{}, a)
+
+	a, err = ParseAlignment("+02:00")
+	if assert.NoError(t, err) {
+		_, offset := time.Now().In(a.Location).Zone()
+		assert.Equal(t, 2*3600, offset)
+	}
+
+	a, err = ParseAlignment("UTC-5")
+	if assert.NoError(t, err) {
+		_, offset := time.Now().In(a.Location).Zone()
+		assert.Equal(t, -5*3600, offset)
+	}
+
+	a, err = ParseAlignment("Europe/Athens")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Europe/Athens", a.Location.String())
+	}
+
+	a, err = ParseAlignment("NY-close")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "America/New_York", a.Location.String())
+		assert.Equal(t, 7*time.Hour, a.Shift)
+	}
+
+	_, err = ParseAlignment("Mars/Olympus")
+	assert.Error(t, err)
+	_, err = ParseAlignment("+25:00")
+	assert.Error(t, err)
+}
+
+func TestTimeframe_NewYorkClose(t *testing.T) {
+	nyClose, err := NewYorkClose()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	d1 := D1.WithAlignment(nyClose)
+
+	// Summer, New York is UTC-4
+	summer := time.Date(2021, time.July, 6, 20, 59, 0, 0, time.UTC)
+	assert.True(t, time.Date(2021, time.July, 5, 21, 0, 0, 0, time.UTC).Equal(d1.BarStart(summer)))
+	assert.True(t, time.Date(2021, time.July, 6, 21, 0, 0, 0, time.UTC).Equal(d1.BarStart(summer.Add(time.Minute))))
+
+	// Winter, New York is UTC-5
+	winter := time.Date(2021, time.January, 6, 21, 59, 0, 0, time.UTC)
+	assert.True(t, time.Date(2021, time.January, 5, 22, 0, 0, 0, time.UTC).Equal(d1.BarStart(winter)))
+	assert.True(t, time.Date(2021, time.January, 6, 22, 0, 0, 0, time.UTC).Equal(d1.BarStart(winter.Add(time.Minute))))
+
+	// DST starts on Sunday 2021-03-14, the Friday bar closes at 22:00 UTC and the Monday bar at 21:00 UTC
+	friday := d1.BarStart(time.Date(2021, time.March, 12, 12, 0, 0, 0, time.UTC))
+	assert.True(t, time.Date(2021, time.March, 11, 22, 0, 0, 0, time.UTC).Equal(friday))
+	assert.True(t, time.Date(2021, time.March, 12, 22, 0, 0, 0, time.UTC).Equal(d1.NextBarStart(friday)))
+	monday := d1.BarStart(time.Date(2021, time.March, 15, 12, 0, 0, 0, time.UTC))
+	assert.True(t, time.Date(2021, time.March, 14, 21, 0, 0, 0, time.UTC).Equal(monday))
+	assert.True(t, time.Date(2021, time.March, 15, 21, 0, 0, 0, time.UTC).Equal(d1.NextBarStart(monday)))
+}
+
+func TestBuild_NewYorkCloseFiveDailyBars(t *testing.T) {
+	nyClose, err := NewYorkClose()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// A trading week, from the Sunday open to the Friday close, every 30 minutes
+	open := time.Date(2021, time.March, 14, 21, 0, 0, 0, time.UTC)
+	closeTime := time.Date(2021, time.March, 19, 21, 0, 0, 0, time.UTC)
+	ticks := make([]*tickdata.TickData, 0)
+	for at := open; at.Before(closeTime); at = at.Add(30 * time.Minute) {
+		ticks = append(ticks, tick(at, 1.19, 1.1901, 1))
+	}
+
+	days := Build(D1.WithAlignment(nyClose), ticks)
+	if assert.Len(t, days, 5) {
+		for i, day := range days {
+			assert.Equal(t, 48, day.TickCount)
+			assert.Equal(t, 17, day.Time.Hour())
+			assert.Equal(t, time.Weekday(i), day.Time.Weekday())
+		}
+	}
+}
+
+func TestAlignment_WallClock(t *testing.T) {
+	nyClose, err := NewYorkClose()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// GMT+2 in winter, GMT+3 once New York moved to daylight saving time
+	winter := time.Date(2021, time.January, 4, 21, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2021, time.January, 4, 23, 30, 0, 0, time.UTC), nyClose.WallClock(winter))
+	summer := time.Date(2021, time.March, 15, 21, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2021, time.March, 16, 0, 30, 0, 0, time.UTC), nyClose.WallClock(summer))
+
+	assert.Equal(t, winter, Alignment{}.WallClock(winter))
+}
@@ -0,0 +1,161 @@
+// Package bars aggregates tick data into bid, ask and mid OHLC bars
+package bars
+
+import (
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"iter"
+	"math"
+	"time"
+)
+
+// Bar of a timeframe. Spreads are in price, ask minus bid.
+type Bar struct {
+	Time time.Time
+
+	OpenBid  float64
+	HighBid  float64
+	LowBid   float64
+	CloseBid float64
+
+	OpenAsk  float64
+	HighAsk  float64
+	LowAsk   float64
+	CloseAsk float64
+
+	OpenMid  float64
+	HighMid  float64
+	LowMid   float64
+	CloseMid float64
+
+	TickCount int
+	VolumeBid float64
+	VolumeAsk float64
+
+	MinSpread float64
+	MaxSpread float64
+	AvgSpread float64
+}
+
+// Add updates the bar with the tick
+func (b *Bar) Add(tick *tickdata.TickData) {
+	mid := (tick.Bid + tick.Ask) / 2
+	spread := tick.Ask - tick.Bid
+
+	if b.TickCount == 0 {
+		b.OpenBid, b.HighBid, b.LowBid = tick.Bid, tick.Bid, tick.Bid
+		b.OpenAsk, b.HighAsk, b.LowAsk = tick.Ask, tick.Ask, tick.Ask
+		b.OpenMid, b.HighMid, b.LowMid = mid, mid, mid
+		b.MinSpread, b.MaxSpread = spread, spread
+	} else {
+		b.HighBid = math.Max(b.HighBid, tick.Bid)
+		b.LowBid = math.Min(b.LowBid, tick.Bid)
+		b.HighAsk = math.Max(b.HighAsk, tick.Ask)
+		b.LowAsk = math.Min(b.LowAsk, tick.Ask)
+		b.HighMid = math.Max(b.HighMid, mid)
+		b.LowMid = math.Min(b.LowMid, mid)
+		b.MinSpread = math.Min(b.MinSpread, spread)
+		b.MaxSpread = math.Max(b.MaxSpread, spread)
+	}
+	b.CloseBid = tick.Bid
+	b.CloseAsk = tick.Ask
+	b.CloseMid = mid
+
+	b.TickCount++
+	b.VolumeBid += tick.VolumeBid
+	b.VolumeAsk += tick.VolumeAsk
+	b.AvgSpread += (spread - b.AvgSpread) / float64(b.TickCount)
+}
+
+// Aggregate the ticks into a single bar starting at barTime
+func Aggregate(barTime time.Time, ticks []*tickdata.TickData) Bar {
+	bar := Bar{Time: barTime}
+	for _, tick := range ticks {
+		bar.Add(tick)
+	}
+
+	return bar
+}
+
+// Builder aggregates a chronological tick stream into bars
+type Builder struct {
+	timeframe Timeframe
+	bar       Bar
+	end       time.Time
+}
+
+func NewBuilder(timeframe Timeframe) *Builder {
+	return &Builder{timeframe: timeframe}
+}
+
+// Add the tick, the previous bar is returned once the tick opens a new one
+func (b *Builder) Add(tick *tickdata.TickData) (completed Bar, isCompleted bool) {
+	t := tick.UTC()
+	if b.bar.TickCount > 0 && !t.Before(b.end) {
+		completed, isCompleted = b.bar, true
+		b.bar = Bar{}
+	}
+
+	if b.bar.TickCount == 0 {
+		b.bar.Time = b.timeframe.BarStart(t)
+		b.end = b.timeframe.NextBarStart(b.bar.Time)
+	}
+	b.bar.Add(tick)
+
+	return
+}
+
+// Current returns the bar being built, false if there is none
+func (b *Builder) Current() (Bar, bool) {
+	return b.bar, b.bar.TickCount > 0
+}
+
+// Flush returns the bar being built, false if there is none, the next tick opens a new bar
+func (b *Builder) Flush() (Bar, bool) {
+	bar, ok := b.Current()
+	b.bar = Bar{}
+
+	return bar, ok
+}
+
+// Build aggregates the chronological ticks into bars
+func Build(timeframe Timeframe, ticks []*tickdata.TickData) []Bar {
+	r := make([]Bar, 0)
+	builder := NewBuilder(timeframe)
+	for _, tick := range ticks {
+		if bar, ok := builder.Add(tick); ok {
+			r = append(r, bar)
+		}
+	}
+	if bar, ok := builder.Flush(); ok {
+		r = append(r, bar)
+	}
+
+	return r
+}
+
+// FromTicks yields the bars of a chronological tick source, like stream.Stream.All().
+// A tick source error is yielded with an empty bar, ticks with an error are ignored.
+func FromTicks(timeframe Timeframe, ticks iter.Seq2[*tickdata.TickData, error]) iter.Seq2[Bar, error] {
+	return func(yield func(Bar, error) bool) {
+		builder := NewBuilder(timeframe)
+		for tick, err := range ticks {
+			if err != nil {
+				if !yield(Bar{}, err) {
+					return
+				}
+				continue
+			}
+			if tick == nil {
+				continue
+			}
+
+			if bar, ok := builder.Add(tick); ok && !yield(bar, nil) {
+				return
+			}
+		}
+
+		if bar, ok := builder.Flush(); ok {
+			yield(bar, nil)
+		}
+	}
+}
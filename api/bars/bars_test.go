@@ -0,0 +1,185 @@
+package bars
+
+import (
+	"errors"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/stretchr/testify/assert"
+	"iter"
+	"testing"
+	"time"
+)
+
+func tick(at time.Time, bid, ask, volume float64) *tickdata.TickData {
+	return &tickdata.TickData{
+		Symbol:    "EURUSD",
+		Timestamp: at.UnixMilli(),
+		Ask:       ask,
+		Bid:       bid,
+		VolumeAsk: volume,
+		VolumeBid: volume,
+	}
+}
+
+func TestParseTimeframe(t *testing.T) {
+	tf, err := ParseTimeframe("M15")
+	assert.NoError(t, err)
+	assert.Equal(t, M15, tf)
+	assert.Equal(t, uint32(15), tf.Minutes())
+
+	tf, err = ParseTimeframe("MN1")
+	assert.NoError(t, err)
+	assert.Equal(t, "MN1", tf.String())
+	assert.Equal(t, uint32(43200), tf.Minutes())
+
+	for _, invalid := range []string{"", "M", "M0", "Y1", "XM1"} {
+		_, err = ParseTimeframe(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	h := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	ticks := []*tickdata.TickData{
+		tick(h.Add(5*time.Second), 1.10000, 1.10002, 1),
+		tick(h.Add(20*time.Second), 1.10010, 1.10014, 2),
+		tick(h.Add(40*time.Second), 1.09990, 1.09993, 1),
+		tick(h.Add(59*time.Second), 1.10005, 1.10006, 1),
+		tick(h.Add(3*time.Minute), 1.10020, 1.10022, 1),
+	}
+
+	bars := Build(M1, ticks)
+	if !assert.Len(t, bars, 2) {
+		t.FailNow()
+	}
+
+	bar := bars[0]
+	assert.Equal(t, h, bar.Time)
+	assert.Equal(t, 4, bar.TickCount)
+	assert.Equal(t, []float64{1.10000, 1.10010, 1.09990, 1.10005}, []float64{bar.OpenBid, bar.HighBid, bar.LowBid, bar.CloseBid})
+	assert.Equal(t, []float64{1.10002, 1.10014, 1.09993, 1.10006}, []float64{bar.OpenAsk, bar.HighAsk, bar.LowAsk, bar.CloseAsk})
+	assert.InDelta(t, 1.10012, bar.HighMid, 1e-9)
+	assert.InDelta(t, 1.099915, bar.LowMid, 1e-9)
+	assert.InDelta(t, 1.100055, bar.CloseMid, 1e-9)
+	assert.Equal(t, 5.0, bar.VolumeBid)
+	assert.Equal(t, 5.0, bar.VolumeAsk)
+	assert.InDelta(t, 0.00001, bar.MinSpread, 1e-9)
+	assert.InDelta(t, 0.00004, bar.MaxSpread, 1e-9)
+	assert.InDelta(t, 0.0000250, bar.AvgSpread, 1e-9)
+
+	assert.Equal(t, h.Add(3*time.Minute), bars[1].Time)
+	assert.Equal(t, 1, bars[1].TickCount)
+
+	bars = Build(H1, ticks)
+	assert.Len(t, bars, 1)
+	assert.Equal(t, 5, bars[0].TickCount)
+}
+
+func TestBuilder(t *testing.T) {
+	h := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	builder := NewBuilder(M5)
+
+	_, ok := builder.Current()
+	assert.False(t, ok)
+
+	_, ok = builder.Add(tick(h.Add(time.Minute), 1.1, 1.1001, 1))
+	assert.False(t, ok)
+	_, ok = builder.Add(tick(h.Add(4*time.Minute), 1.2, 1.2001, 1))
+	assert.False(t, ok)
+
+	current, ok := builder.Current()
+	assert.True(t, ok)
+	assert.Equal(t, 2, current.TickCount)
+
+	completed, ok := builder.Add(tick(h.Add(12*time.Minute), 1.3, 1.3001, 1))
+	assert.True(t, ok)
+	assert.Equal(t, h, completed.Time)
+	assert.Equal(t, 1.2, completed.CloseBid)
+
+	flushed, ok := builder.Flush()
+	assert.True(t, ok)
+	assert.Equal(t, h.Add(10*time.Minute), flushed.Time)
+	_, ok = builder.Flush()
+	assert.False(t, ok)
+}
+
+func TestFromTicks(t *testing.T) {
+	h := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	sourceErr := errors.New("download failed")
+	var source iter.Seq2[*tickdata.TickData, error] = func(yield func(*tickdata.TickData, error) bool) {
+		_ = yield(tick(h, 1.1, 1.1001, 1), nil) &&
+			yield(nil, sourceErr) &&
+			yield(tick(h.Add(2*time.Hour), 1.2, 1.2001, 1), nil)
+	}
+
+	var times []time.Time
+	var errs []error
+	for bar, err := range FromTicks(H1, source) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		times = append(times, bar.Time)
+	}
+	assert.Equal(t, []error{sourceErr}, errs)
+	assert.Equal(t, []time.Time{h, h.Add(2 * time.Hour)}, times)
+
+	count := 0
+	for range FromTicks(H1, source) {
+		count++
+		break
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestTimeframe_Calendar(t *testing.T) {
+	thursday := time.Date(2021, time.January, 7, 15, 30, 0, 0, time.UTC)
+
+	sunday := time.Date(2021, time.January, 3, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, sunday, W1.BarStart(thursday))
+	assert.Equal(t, sunday.AddDate(0, 0, 7), W1.NextBarStart(sunday))
+	assert.Equal(t, sunday, W1.BarStart(sunday))
+
+	monday := time.Date(2021, time.January, 4, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, monday, W1.WithWeekStart(time.Monday).BarStart(thursday))
+	assert.Equal(t, monday.AddDate(0, 0, -7), W1.WithWeekStart(time.Monday).BarStart(sunday))
+
+	w2, _ := ParseTimeframe("W2")
+	start := w2.BarStart(thursday)
+	assert.Equal(t, time.Sunday, start.Weekday())
+	assert.Equal(t, start, w2.BarStart(w2.NextBarStart(start).Add(-time.Second)))
+	assert.Equal(t, w2.NextBarStart(start), w2.BarStart(w2.NextBarStart(start)))
+
+	february := time.Date(2021, time.February, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, february, MN1.BarStart(time.Date(2021, time.February, 28, 23, 59, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC), MN1.NextBarStart(february))
+
+	quarter, _ := ParseTimeframe("MN3")
+	assert.Equal(t, time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC), quarter.BarStart(time.Date(2021, time.May, 10, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC), quarter.NextBarStart(time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC)))
+
+	assert.Equal(t, time.Date(2021, time.January, 7, 0, 0, 0, 0, time.UTC), D1.BarStart(thursday))
+	assert.Equal(t, time.Date(2021, time.January, 7, 12, 0, 0, 0, time.UTC), H4.BarStart(thursday))
+}
+
+func TestBuild_Weekly(t *testing.T) {
+	friday := time.Date(2021, time.January, 8, 21, 0, 0, 0, time.UTC)
+	sunday := time.Date(2021, time.January, 10, 22, 0, 0, 0, time.UTC)
+	ticks := []*tickdata.TickData{
+		tick(friday, 1.22, 1.2201, 1),
+		tick(sunday, 1.23, 1.2301, 1),
+		tick(sunday.Add(24*time.Hour), 1.24, 1.2401, 1),
+	}
+
+	weeks := Build(W1, ticks)
+	if assert.Len(t, weeks, 2) {
+		assert.Equal(t, time.Date(2021, time.January, 3, 0, 0, 0, 0, time.UTC), weeks[0].Time)
+		assert.Equal(t, time.Date(2021, time.January, 10, 0, 0, 0, 0, time.UTC), weeks[1].Time)
+		assert.Equal(t, 2, weeks[1].TickCount)
+	}
+
+	weeks = Build(W1.WithWeekStart(time.Monday), ticks)
+	if assert.Len(t, weeks, 2) {
+		assert.Equal(t, time.Date(2021, time.January, 4, 0, 0, 0, 0, time.UTC), weeks[0].Time)
+		assert.Equal(t, 2, weeks[0].TickCount)
+	}
+}
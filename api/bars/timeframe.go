@@ -0,0 +1,149 @@
+package bars
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var timeframeRegx = regexp.MustCompile(`^(M|H|D|W|MN)(\d+)$`)
+
+// Timeframe of bars like: M1, M5, M15, M30, H1, H4, D1, W1, MN1.
+// Weekly bars open on the week start day (Sunday by default, like MT4) and monthly bars on the first of the month.
+// Bars are aligned in UTC unless an alignment is set.
+type Timeframe struct {
+	unit      string
+	count     int
+	weekStart time.Weekday
+	alignment Alignment
+}
+
+var (
+	M1  = Timeframe{unit: "M", count: 1}
+	M5  = Timeframe{unit: "M", count: 5}
+	M15 = Timeframe{unit: "M", count: 15}
+	M30 = Timeframe{unit: "M", count: 30}
+	H1  = Timeframe{unit: "H", count: 1}
+	H4  = Timeframe{unit: "H", count: 4}
+	D1  = Timeframe{unit: "D", count: 1}
+	W1  = Timeframe{unit: "W", count: 1}
+	MN1 = Timeframe{unit: "MN", count: 1}
+)
+
+var unitMinutes = map[string]uint32{
+	"M":  1,
+	"H":  60,
+	"D":  24 * 60,
+	"W":  7 * 24 * 60,
+	"MN": 30 * 24 * 60,
+}
+
+// ParseTimeframe from input string like M15
+func ParseTimeframe(period string) (Timeframe, error) {
+	ss := timeframeRegx.FindStringSubmatch(period)
+	if len(ss) != 3 {
+		return Timeframe{}, errors.Errorf("invalid timeframe [%s]", period)
+	}
+
+	count, err := strconv.Atoi(ss[2])
+	if err != nil || count < 1 {
+		return Timeframe{}, errors.Errorf("invalid timeframe [%s]", period)
+	}
+
+	return Timeframe{unit: ss[1], count: count}, nil
+}
+
+// WithWeekStart returns the timeframe with weekly bars opening on the given day
+func (tf Timeframe) WithWeekStart(weekStart time.Weekday) Timeframe {
+	tf.weekStart = weekStart
+
+	return tf
+}
+
+// WeekStart returns the day weekly bars open on
+func (tf Timeframe) WeekStart() time.Weekday {
+	return tf.weekStart
+}
+
+// WithAlignment returns the timeframe with bars aligned on the wall clock of the alignment
+func (tf Timeframe) WithAlignment(alignment Alignment) Timeframe {
+	tf.alignment = alignment
+
+	return tf
+}
+
+// Alignment returns the wall clock bars are aligned on
+func (tf Timeframe) Alignment() Alignment {
+	return tf.alignment
+}
+
+func (tf Timeframe) String() string {
+	return tf.unit + strconv.Itoa(tf.count)
+}
+
+// Minutes returns the nominal length of a bar like MT4 periods, a month being 30 days
+func (tf Timeframe) Minutes() uint32 {
+	return unitMinutes[tf.unit] * uint32(tf.count)
+}
+
+func (tf Timeframe) duration() time.Duration {
+	return time.Duration(tf.Minutes()) * time.Minute
+}
+
+// BarStart returns the start of the bar containing t, in the alignment location
+func (tf Timeframe) BarStart(t time.Time) time.Time {
+	return tf.alignment.fromWall(tf.wallBarStart(tf.alignment.toWall(t)))
+}
+
+// NextBarStart returns the start of the bar following the one starting at barStart
+func (tf Timeframe) NextBarStart(barStart time.Time) time.Time {
+	return tf.alignment.fromWall(tf.wallNextBarStart(tf.alignment.toWall(barStart)))
+}
+
+// wallBarStart returns the start of the bar containing the wall clock t
+func (tf Timeframe) wallBarStart(t time.Time) time.Time {
+	switch tf.unit {
+	case "W":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		weekStart := day.AddDate(0, 0, -((int(day.Weekday()) - int(tf.weekStart) + 7) % 7))
+		// Multiple weeks bars are aligned on the first week start of the epoch
+		weeks := int(weekStart.Sub(tf.epochWeekStart()).Hours()) / (7 * 24)
+		return weekStart.AddDate(0, 0, -7*floorMod(weeks, tf.count))
+	case "MN":
+		months := t.Year()*12 + int(t.Month()) - 1
+		months -= floorMod(months, tf.count)
+		return time.Date(months/12, time.Month(months%12+1), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		seconds := t.Unix()
+		delta := int64(tf.duration() / time.Second)
+		return time.Unix(seconds-floorMod64(seconds, delta), 0).UTC()
+	}
+}
+
+func (tf Timeframe) wallNextBarStart(barStart time.Time) time.Time {
+	switch tf.unit {
+	case "W":
+		return barStart.AddDate(0, 0, 7*tf.count)
+	case "MN":
+		return barStart.AddDate(0, tf.count, 0)
+	default:
+		return barStart.Add(tf.duration())
+	}
+}
+
+// epochWeekStart returns the first week start day on or after the unix epoch
+func (tf Timeframe) epochWeekStart() time.Time {
+	epoch := time.Unix(0, 0).UTC()
+
+	return epoch.AddDate(0, 0, (int(tf.weekStart)-int(epoch.Weekday())+7)%7)
+}
+
+func floorMod(a, b int) int {
+	return ((a % b) + b) % b
+}
+
+func floorMod64(a, b int64) int64 {
+	return ((a % b) + b) % b
+}
@@ -0,0 +1,90 @@
+// Package candles reads the minute, hour and day candles published by dukascopy,
+// sparing the tick download when bars are enough
+package candles
+
+import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/internal/bi5"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// Candle of a single period, Time is the candle start in UTC
+type Candle = bi5.Candle
+
+// Side of the quotes the candles are built from
+type Side = bi5.CandleSide
+
+const (
+	Bid = bi5.CandleBid
+	Ask = bi5.CandleAsk
+)
+
+// Period of a candle. Minute candles are published per day, hour candles per month and day candles per year.
+type Period = bi5.CandlePeriod
+
+const (
+	Minute = bi5.CandleMinute
+	Hour   = bi5.CandleHour
+	Day    = bi5.CandleDay
+)
+
+// ErrNotPublished is returned along the published candles when the requested range reaches a day, month or year
+// that isn't complete yet. Dukascopy publishes its candle file afterward, build the newer bars from the ticks.
+type ErrNotPublished struct {
+	Symbol string
+	Period Period
+	// Start of the first period that isn't published, the candles are complete before it
+	Start time.Time
+}
+
+func (e *ErrNotPublished) Error() string {
+	return "candles [" + e.Symbol + ": " + string(e.Period) + "] are not published from " + e.Start.UTC().Format(time.DateTime)
+}
+
+// Fetch is FetchContext without cancellation
+func Fetch(instrument *instrument.Metadata, side Side, period Period, from, to time.Time, folder string, opts ...datafeed.Option) ([]Candle, error) {
+	return FetchContext(context.Background(), instrument, side, period, from, to, folder, opts...)
+}
+
+// FetchContext returns the candles starting between from and to (both inclusive) in chronological order.
+// Missing files are downloaded into the folder cache, sharing the tick data cache layout and markers.
+// Dukascopy only publishes a file once its day, month or year is complete, when to reaches a newer period
+// the candles before it are returned with an *ErrNotPublished error. Synthetic instruments have no candles, their bars are built from the ticks.
+func FetchContext(ctx context.Context, instrument *instrument.Metadata, side Side, period Period, from, to time.Time, folder string, opts ...datafeed.Option) ([]Candle, error) {
+	if instrument.Synthetic() != nil {
+		return nil, errors.Errorf("no candles for the synthetic instrument [%s], build the bars from its ticks", instrument.Code())
+	}
+	from = from.UTC()
+	to = to.UTC()
+	if to.Before(from) {
+		from, to = to, from
+	}
+
+	downloader := bi5.NewDownloader(folder, opts...)
+	now := time.Now()
+	r := make([]Candle, 0)
+	for fileStart := period.FileStart(from); !fileStart.After(to); fileStart = period.NextFileStart(fileStart) {
+		if period.NextFileStart(fileStart).After(now) {
+			return r, &ErrNotPublished{Symbol: instrument.Code(), Period: period, Start: fileStart}
+		}
+
+		if err := downloader.DownloadCandlesContext(ctx, instrument.Code(), side, period, fileStart); err != nil {
+			return r, err
+		}
+
+		candles, err := bi5.ReadCandles(folder, instrument, side, period, fileStart)
+		if err != nil {
+			return r, err
+		}
+		for _, c := range candles {
+			if !(c.Time.Before(from) || c.Time.After(to)) {
+				r = append(r, c)
+			}
+		}
+	}
+
+	return r, nil
+}
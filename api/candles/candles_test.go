@@ -0,0 +1,102 @@
+package candles
+
+import (
+	"context"
+	"fmt"
+	"github.com/edward-yakop/go-duka/api/datafeed"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/internal/bi5"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFetch_Day(t *testing.T) {
+	mirror := t.TempDir()
+	day := 24 * time.Hour
+	bi5test.WriteCandlesMirror(t, mirror, "EURUSD", "2020", "BID_candles_day_1.bi5", bi5test.EncodeCandles(t, 100000,
+		bi5test.CandleRecord{Offset: 0, Open: 1.12125, High: 1.12210, Low: 1.11980, Close: 1.12100, Volume: 12.5},
+		bi5test.CandleRecord{Offset: day, Open: 1.12100, High: 1.12300, Low: 1.11500, Close: 1.11600, Volume: 20},
+		bi5test.CandleRecord{Offset: 2 * day, Open: 1.11600, High: 1.11700, Low: 1.11400, Close: 1.11500, Volume: 18},
+	))
+	bi5test.WriteCandlesMirror(t, mirror, "EURUSD", "2021", "BID_candles_day_1.bi5", bi5test.EncodeCandles(t, 100000,
+		bi5test.CandleRecord{Offset: 0, Open: 1.22000, High: 1.22100, Low: 1.21900, Close: 1.22050, Volume: 3},
+	))
+
+	folder := t.TempDir()
+	from := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	candles, err := Fetch(instrument.GetMetadata("EURUSD"), Bid, Day, from, to, folder, bi5test.FileSource(t, mirror))
+	if !assert.NoError(t, err) || !assert.Len(t, candles, 3) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, Candle{
+		Symbol: "EURUSD",
+		Side:   Bid,
+		Period: Day,
+		Time:   from,
+		Open:   1.121,
+		High:   1.123,
+		Low:    1.115,
+		Close:  1.116,
+		Volume: 20,
+	}, candles[0])
+	assert.Equal(t, to, candles[2].Time)
+	assert.FileExists(t, bi5.CandleFilePath(folder, "EURUSD", Bid, Day, from))
+
+	// Cached files are read without the source
+	candles, err = Fetch(instrument.GetMetadata("EURUSD"), Bid, Day, from, to, folder, datafeed.WithOffline())
+	assert.NoError(t, err)
+	assert.Len(t, candles, 3)
+}
+
+func TestFetch_MinuteUsesZeroBasedMonth(t *testing.T) {
+	mirror := t.TempDir()
+	bi5test.WriteCandlesMirror(t, mirror, "EURUSD", "2021/00/08", "ASK_candles_min_1.bi5", bi5test.EncodeCandles(t, 100000,
+		bi5test.CandleRecord{Offset: 10 * time.Hour, Open: 1.22501, High: 1.22510, Low: 1.22490, Close: 1.22505, Volume: 1},
+		bi5test.CandleRecord{Offset: 10*time.Hour + time.Minute, Open: 1.22505, High: 1.22520, Low: 1.22500, Close: 1.22515, Volume: 1},
+	))
+
+	folder := t.TempDir()
+	at := time.Date(2021, time.January, 8, 10, 1, 0, 0, time.UTC)
+	candles, err := FetchContext(context.Background(), instrument.GetMetadata("EURUSD"), Ask, Minute, at, at, folder, bi5test.FileSource(t, mirror))
+	if assert.NoError(t, err) && assert.Len(t, candles, 1) {
+		assert.Equal(t, at, candles[0].Time)
+		assert.InDelta(t, 1.22515, candles[0].Close, 1e-9)
+	}
+
+	// Missing days are marked like missing tick hours
+	_, err = Fetch(instrument.GetMetadata("EURUSD"), Ask, Minute, at.AddDate(0, 0, 1), at.AddDate(0, 0, 1), folder, bi5test.FileSource(t, mirror))
+	assert.NoError(t, err)
+	assert.FileExists(t, bi5.CandleFilePath(folder, "EURUSD", Ask, Minute, at.AddDate(0, 0, 1))+bi5.MarkerNotFound.Ext())
+}
+
+func TestFetch_OfflineNotCached(t *testing.T) {
+	at := time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC)
+	_, err := Fetch(instrument.GetMetadata("EURUSD"), Bid, Hour, at, at, t.TempDir(), datafeed.WithOffline())
+	var notCached *datafeed.ErrNotCached
+	if assert.ErrorAs(t, err, &notCached) {
+		assert.Equal(t, at, notCached.Hour)
+	}
+}
+
+func TestFetch_NotPublished(t *testing.T) {
+	year := time.Now().UTC().Year()
+	mirror := t.TempDir()
+	bi5test.WriteCandlesMirror(t, mirror, "EURUSD", fmt.Sprint(year-1), "BID_candles_day_1.bi5", bi5test.EncodeCandles(t, 100000,
+		bi5test.CandleRecord{Offset: 0, Open: 1.22000, High: 1.22100, Low: 1.21900, Close: 1.22050, Volume: 3},
+	))
+
+	// Up to today, the current year file isn't published
+	from := time.Date(year-1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	candles, err := Fetch(instrument.GetMetadata("EURUSD"), Bid, Day, from, time.Now(), t.TempDir(), bi5test.FileSource(t, mirror))
+	assert.Len(t, candles, 1)
+	var notPublished *ErrNotPublished
+	if assert.ErrorAs(t, err, &notPublished) {
+		assert.Equal(t, "EURUSD", notPublished.Symbol)
+		assert.Equal(t, Day, notPublished.Period)
+		assert.Equal(t, time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC), notPublished.Start)
+	}
+}
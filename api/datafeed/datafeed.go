@@ -0,0 +1,146 @@
+package datafeed
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultURL is the root of the public dukascopy datafeed
+const DefaultURL = "https://datafeed.dukascopy.com/datafeed"
+
+// RevalidatePolicy decides when the .notFound and .empty markers of an hour are checked again.
+// Markers written before dukascopy published an hour would otherwise hide its data forever.
+type RevalidatePolicy struct {
+	// Recent revalidates markers of hours that started less than Recent ago. 0 disables it.
+	Recent time.Duration
+	// Settle revalidates markers created less than Settle after the end of the hour they describe. 0 disables it.
+	Settle time.Duration
+}
+
+// DefaultRevalidatePolicy revalidates markers written within a day of the hour they describe
+var DefaultRevalidatePolicy = RevalidatePolicy{
+	Settle: 24 * time.Hour,
+}
+
+// IsStale returns true if the marker of dayHour, last modified at markerTime, must be checked again
+func (p RevalidatePolicy) IsStale(dayHour, markerTime, now time.Time) bool {
+	return p.IsStalePeriod(dayHour, dayHour.Add(time.Hour), markerTime, now)
+}
+
+// IsStalePeriod returns true if the marker of a file covering [start, end), last modified at markerTime,
+// must be checked again
+func (p RevalidatePolicy) IsStalePeriod(start, end, markerTime, now time.Time) bool {
+	if p.Recent > 0 && now.Sub(start) < p.Recent {
+		return true
+	}
+
+	return p.Settle > 0 && markerTime.Before(end.Add(p.Settle))
+}
+
+// ErrNotCached is returned in offline mode when an hour is missing from the cache.
+// For candle files, Hour is the start of the period covered by the file.
+type ErrNotCached struct {
+	Symbol string
+	Hour   time.Time
+}
+
+func (e *ErrNotCached) Error() string {
+	return "tick data [" + e.Symbol + ": " + e.Hour.UTC().Format("2006-01-02:15H") + "] is not cached"
+}
+
+// Config describes where bi5 files are fetched from
+type Config struct {
+	source      string
+	revalidate  RevalidatePolicy
+	offline     bool
+	strictRange bool
+}
+
+// Option configures the datafeed used to populate the bi5 cache
+type Option func(c *Config)
+
+// WithSource sets the datafeed root. Both http(s):// and file:// roots are supported,
+// file:// roots must point at a mirror of the dukascopy datafeed tree.
+func WithSource(source string) Option {
+	return func(c *Config) {
+		if source != "" {
+			c.source = strings.TrimRight(source, "/")
+		}
+	}
+}
+
+// WithRevalidatePolicy sets when .notFound and .empty markers are checked again
+func WithRevalidatePolicy(policy RevalidatePolicy) Option {
+	return func(c *Config) {
+		c.revalidate = policy
+	}
+}
+
+// WithOffline only reads from the cache and never touches the network.
+// Hours missing from the cache fail with *ErrNotCached, .notFound and .empty markers are still respected.
+func WithOffline() Option {
+	return func(c *Config) {
+		c.offline = true
+	}
+}
+
+// NewConfig returns the configuration after applying the given options
+func NewConfig(opts ...Option) Config {
+	c := Config{
+		source:     DefaultURL,
+		revalidate: DefaultRevalidatePolicy,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&c)
+		}
+	}
+
+	return c
+}
+
+// Source returns the datafeed root without trailing slash
+func (c Config) Source() string {
+	return c.source
+}
+
+// RevalidatePolicy returns when .notFound and .empty markers are checked again
+func (c Config) RevalidatePolicy() RevalidatePolicy {
+	return c.revalidate
+}
+
+// IsOffline returns true if only the cache is read
+func (c Config) IsOffline() bool {
+	return c.offline
+}
+
+// IsFileSource returns true if the datafeed root is a local mirror
+func (c Config) IsFileSource() bool {
+	return strings.HasPrefix(c.source, "file://")
+}
+
+// ParseSource validates the datafeed root
+func ParseSource(source string) (string, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid datafeed source [%s]", source)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return "", errors.Errorf("invalid datafeed source [%s], missing host", source)
+		}
+	case "file":
+		if u.Path == "" {
+			return "", errors.Errorf("invalid datafeed source [%s], missing path", source)
+		}
+	default:
+		return "", errors.Errorf("invalid datafeed source [%s], supported schemes are http, https and file", source)
+	}
+
+	return strings.TrimRight(source, "/"), nil
+}
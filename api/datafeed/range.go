@@ -0,0 +1,68 @@
+package datafeed
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/edward-yakop/go-duka/api/instrument"
+)
+
+// ErrBeforeDataStart is returned in strict range mode when a request starts before the instrument tick data
+type ErrBeforeDataStart struct {
+	Symbol    string
+	Start     time.Time
+	DataStart time.Time
+}
+
+func (e *ErrBeforeDataStart) Error() string {
+	return "requested start [" + e.Start.UTC().Format("2006-01-02:15H") + "] is before [" + e.Symbol +
+		"] tick data start [" + e.DataStart.UTC().Format("2006-01-02:15H") + "]"
+}
+
+// WithStrictRange fails requests starting before the instrument tick data with *ErrBeforeDataStart.
+// By default, such requests are clamped to the data start and the skipped range is logged.
+func WithStrictRange() Option {
+	return func(c *Config) {
+		c.strictRange = true
+	}
+}
+
+// IsStrictRange returns true if requests starting before the instrument tick data fail
+func (c Config) IsStrictRange() bool {
+	return c.strictRange
+}
+
+// DataStart returns the first hour with tick data of the instrument, its MinStartDate if unknown
+func DataStart(metadata *instrument.Metadata) time.Time {
+	if start := metadata.StartHourForTicks(); !start.IsZero() {
+		return start
+	}
+
+	return metadata.MinStartDate()
+}
+
+// ClampRange checks the [start, end] request against the instrument data start.
+// In strict range mode it fails with *ErrBeforeDataStart, otherwise start is moved to the data start.
+// When the whole range is before the data start, the returned start is after the returned end.
+func (c Config) ClampRange(metadata *instrument.Metadata, start, end time.Time) (time.Time, time.Time, error) {
+	dataStart := DataStart(metadata)
+	if dataStart.IsZero() || !start.Before(dataStart) {
+		return start, end, nil
+	}
+
+	if c.strictRange {
+		return start, end, &ErrBeforeDataStart{Symbol: metadata.Code(), Start: start, DataStart: dataStart}
+	}
+
+	skippedEnd := dataStart
+	if end.Before(skippedEnd) {
+		skippedEnd = end
+	}
+	slog.Warn("Skipping range before the instrument tick data start",
+		slog.String("instrument", metadata.Code()),
+		slog.Time("from", start.UTC()),
+		slog.Time("to", skippedEnd.UTC()),
+	)
+
+	return dataStart.In(start.Location()), end, nil
+}
@@ -0,0 +1,51 @@
+//go:build ignore
+
+// gen_snapshot retrieves the instrument metadata published at URL into the embedded snapshot and versions it
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/go-resty/resty/v2"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	snapshotPath = "data/instrument-meta-data.json"
+	versionPath  = "snapshot_version.go"
+)
+
+const versionTemplate = `// Code generated by gen_snapshot.go; DO NOT EDIT.
+
+package instrument
+
+// SnapshotVersion is the date the embedded instrument metadata was taken from URL.
+const SnapshotVersion = %q
+`
+
+func main() {
+	resp, err := resty.New().R().Get(instrument.URL)
+	if err != nil {
+		log.Fatalf("failed to retrieve [%s]: %v", instrument.URL, err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		log.Fatalf("failed to retrieve [%s], unexpected http status [%d]", instrument.URL, resp.StatusCode())
+	}
+
+	// Never embed a json the package can't load
+	if err = instrument.LoadMetadataFromReader(bytes.NewReader(resp.Body())); err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile(snapshotPath, resp.Body(), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	version := fmt.Sprintf(versionTemplate, time.Now().UTC().Format(time.DateOnly))
+	if err = os.WriteFile(versionPath, []byte(version), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
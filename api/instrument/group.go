@@ -0,0 +1,91 @@
+package instrument
+
+import "strings"
+
+// Group is the asset class of an instrument
+type Group string
+
+const (
+	GroupFxMajor     Group = "fx_major"
+	GroupFxMinor     Group = "fx_minor"
+	GroupFxExotic    Group = "fx_exotic"
+	GroupMetals      Group = "metals"
+	GroupIndices     Group = "indices"
+	GroupCommodities Group = "commodities"
+	GroupCrypto      Group = "crypto"
+	GroupStocks      Group = "stocks"
+	GroupOther       Group = "other"
+)
+
+// Groups lists all groups
+var Groups = []Group{
+	GroupFxMajor, GroupFxMinor, GroupFxExotic, GroupMetals, GroupIndices, GroupCommodities, GroupCrypto, GroupStocks, GroupOther,
+}
+
+// IsFx returns true for currency pairs
+func (g Group) IsFx() bool {
+	return g == GroupFxMajor || g == GroupFxMinor || g == GroupFxExotic
+}
+
+var (
+	fxMajors = map[string]bool{
+		"EURUSD": true, "GBPUSD": true, "USDJPY": true, "USDCHF": true, "AUDUSD": true, "USDCAD": true, "NZDUSD": true,
+	}
+	majorCurrencies = map[string]bool{
+		"USD": true, "EUR": true, "GBP": true, "JPY": true, "CHF": true, "AUD": true, "CAD": true, "NZD": true,
+	}
+	metals = map[string]bool{
+		"XAU": true, "XAG": true, "XPT": true, "XPD": true, "XCU": true,
+	}
+	cryptos = map[string]bool{
+		"BTC": true, "ETH": true, "LTC": true, "XRP": true, "BCH": true, "EOS": true, "XLM": true, "ADA": true,
+		"DOT": true, "LNK": true, "UNI": true, "MKR": true, "AVE": true, "BAT": true, "CMP": true, "DSH": true,
+		"ENJ": true, "MAT": true, "TRX": true, "XMR": true, "YFI": true, "SOL": true, "DOG": true,
+	}
+)
+
+// classify the instrument from its dukascopy name, i.e. EUR/USD, XAU/USD, USA500.IDX/USD or A.US/USD
+func classify(name string) (group Group, baseCurrency, quoteCurrency string) {
+	left, quote, ok := strings.Cut(strings.ToUpper(name), "/")
+	if !ok {
+		return GroupOther, "", ""
+	}
+
+	if i := strings.LastIndex(left, "."); i >= 0 {
+		switch left[i+1:] {
+		case "IDX":
+			return GroupIndices, "", quote
+		case "CMD":
+			return GroupCommodities, "", quote
+		case "TR":
+			return GroupOther, "", quote
+		default:
+			// Stocks and ETFs are suffixed with the exchange country, i.e. AAPL.US/USD
+			return GroupStocks, "", quote
+		}
+	}
+
+	switch {
+	case metals[left]:
+		return GroupMetals, left, quote
+	case cryptos[left] || cryptos[quote]:
+		return GroupCrypto, left, quote
+	case len(left) != 3 || len(quote) != 3:
+		return GroupOther, left, quote
+	case fxMajors[left+quote]:
+		return GroupFxMajor, left, quote
+	case majorCurrencies[left] && majorCurrencies[quote]:
+		return GroupFxMinor, left, quote
+	default:
+		return GroupFxExotic, left, quote
+	}
+}
+
+// pipSize is ten points for instruments quoted with a fractional pip (3 or 5 digits), otherwise a point
+func pipSize(group Group, digits int, pointSize float64) float64 {
+	if (group.IsFx() || group == GroupMetals) && (digits == 3 || digits == 5) {
+		return pointSize * 10
+	}
+
+	return pointSize
+}
@@ -1,11 +1,16 @@
 package instrument
 
 import (
+	"bytes"
+	"context"
+	_ "embed"
 	"encoding/json"
 	"fmt"
-	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+	"io"
 	"log/slog"
 	"math"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,14 +20,25 @@ import (
 // This file is a port of https://github.com/Leo4815162342/dukascopy-tools/blob/master/packages/dukascopy-node/src/config/instruments-metadata.ts
 
 type Metadata struct {
-	code              string
-	name              string
-	description       string
-	minStartDate      time.Time
-	decimalFactor     float64
-	minStartDateDaily time.Time
+	code               string
+	name               string
+	description        string
+	minStartDate       time.Time
+	decimalFactor      float64
+	minStartDateDaily  time.Time
+	minStartDateHourly time.Time
+	startHourForTicks  time.Time
+
+	baseCurrency  string
+	quoteCurrency string
+	digits        int
+	pointSize     float64
+	pipSize       float64
+	group         Group
 
 	priceFormat string
+
+	synthetic *Synthetic
 }
 
 func (m *Metadata) Code() string {
@@ -73,6 +89,78 @@ func (m *Metadata) MinStartDateDaily() time.Time {
 	return m.minStartDateDaily
 }
 
+func (m *Metadata) MinStartDateHourly() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+
+	return m.minStartDateHourly
+}
+
+// StartHourForTicks returns the hour of the first available tick data
+func (m *Metadata) StartHourForTicks() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+
+	return m.startHourForTicks
+}
+
+// BaseCurrency returns the base currency of currency pairs, metals and crypto, i.e. EUR for EUR/USD.
+// Returns blank for indices, commodities and stocks.
+func (m *Metadata) BaseCurrency() string {
+	if m == nil {
+		return ""
+	}
+
+	return m.baseCurrency
+}
+
+// QuoteCurrency returns the currency the price is quoted in, i.e. USD for EUR/USD or USA500.IDX/USD
+func (m *Metadata) QuoteCurrency() string {
+	if m == nil {
+		return ""
+	}
+
+	return m.quoteCurrency
+}
+
+// Digits returns the number of digits after the decimal point
+func (m *Metadata) Digits() int {
+	if m == nil {
+		return 0
+	}
+
+	return m.digits
+}
+
+// PointSize returns the smallest price change, i.e. 0.00001 for EUR/USD
+func (m *Metadata) PointSize() float64 {
+	if m == nil {
+		return 0
+	}
+
+	return m.pointSize
+}
+
+// PipSize returns the conventional pip, i.e. 0.0001 for EUR/USD and 0.01 for USD/JPY.
+// It's the point size for instruments that are not quoted in fractional pips.
+func (m *Metadata) PipSize() float64 {
+	if m == nil {
+		return 0
+	}
+
+	return m.pipSize
+}
+
+func (m *Metadata) Group() Group {
+	if m == nil {
+		return GroupOther
+	}
+
+	return m.group
+}
+
 func (m *Metadata) PriceToString(price float64) string {
 	if m == nil {
 		return ""
@@ -112,7 +200,7 @@ var s sync.RWMutex
 // GetMetadata returns instrument with requested code.
 // Returns nil if not found
 func GetMetadata(code string) *Metadata {
-	LoadMetadataFromJson(false)
+	ensureLoaded()
 
 	s.RLock()
 	r := codeToInstrument[strings.ToUpper(code)]
@@ -122,7 +210,7 @@ func GetMetadata(code string) *Metadata {
 }
 
 func GetMetadataByName(name string) *Metadata {
-	LoadMetadataFromJson(false)
+	ensureLoaded()
 
 	s.RLock()
 	r := nameToInstrument[name]
@@ -143,68 +231,126 @@ type Instrument struct {
 
 var URL = "https://raw.githubusercontent.com/Leo4815162342/dukascopy-node/master/src/utils/instrument-meta-data/generated/instrument-meta-data.json"
 
-func LoadMetadataFromJson(isForce bool) {
-	if len(codeToInstrument) > 0 && !isForce {
-		return
-	}
+//go:generate go run gen_snapshot.go
+
+// snapshot is the embedded instrument metadata taken from URL, see SnapshotVersion. It's used by default,
+// use Refresh to retrieve the latest instrument metadata.
+//
+//go:embed data/instrument-meta-data.json
+var snapshot []byte
+
+var snapshotOnce sync.Once
+
+// ensureLoaded loads the embedded snapshot unless metadata was explicitly loaded before
+func ensureLoaded() {
+	snapshotOnce.Do(func() {
+		if err := loadMetadata(bytes.NewReader(snapshot)); err != nil {
+			slog.Error(
+				"failed to load embedded instrument metadata",
+				slog.String("version", SnapshotVersion),
+				slog.Any("error", err),
+			)
+		}
+	})
+}
 
-	resp, getErr := resty.New().R().
-		SetDoNotParseResponse(true).
-		Get(URL)
+// LoadMetadataFromFile replaces the instrument metadata with the content of the json file
+func LoadMetadataFromFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open instrument metadata ["+path+"]")
+	}
+	defer func(f *os.File) { _ = f.Close() }(f)
 
-	if getErr != nil {
-		slog.Warn(
-			"Failed to retrieve dukas instrument",
-			slog.String("url", URL),
-			slog.Any("error", getErr),
-		)
+	return LoadMetadataFromReader(f)
+}
 
-		return
-	}
+// LoadMetadataFromReader replaces the instrument metadata with the json content of the reader.
+// The json format is the one published at URL.
+func LoadMetadataFromReader(r io.Reader) error {
+	// Explicitly loaded metadata must not be overridden by the embedded snapshot
+	snapshotOnce.Do(func() {})
 
-	if resp.IsError() {
-		statusCode := resp.RawResponse.StatusCode
-		slog.Error(
-			"failed to retrieve metadata",
-			slog.Int("httpStatusCode", statusCode),
-			slog.String("rawResponse", string(resp.Body())),
-		)
+	return loadMetadata(r)
+}
 
-		return
+func loadMetadata(r io.Reader) error {
+	instruments := map[string]Instrument{}
+	if err := json.NewDecoder(r).Decode(&instruments); err != nil {
+		return errors.Wrap(err, "failed to unmarshal dukas instrument")
+	}
+	if len(instruments) == 0 {
+		return errors.New("no dukas instrument found")
 	}
 
 	tCodeToInstrument := map[string]*Metadata{}
 	tNameToInstrument := map[string]*Metadata{}
-
-	instruments := map[string]Instrument{}
-	if unmarshalErr := json.NewDecoder(resp.RawBody()).Decode(&instruments); unmarshalErr != nil {
-		slog.Error(
-			"failed to unmarshal dukas instrument",
-			slog.Any("error", unmarshalErr),
-			slog.String("url", URL),
-		)
-
-		return
-	}
 	for instrumentCode, instrument := range instruments {
-		metadata := jsonToMetadata(instrumentCode, instrument)
-		tCodeToInstrument[metadata.Code()] = metadata
-		tNameToInstrument[metadata.Name()] = metadata
+		register(tCodeToInstrument, tNameToInstrument, jsonToMetadata(instrumentCode, instrument))
 	}
 
 	s.Lock()
+	// The registered synthetic instruments are defined again from the new legs
+	for _, d := range synthetics {
+		metadata, err := d.define(tCodeToInstrument, tNameToInstrument)
+		if err != nil {
+			slog.Warn("Dropped synthetic instrument", slog.String("code", d.code), slog.Any("error", err))
+			continue
+		}
+		register(tCodeToInstrument, tNameToInstrument, metadata)
+	}
 	codeToInstrument = tCodeToInstrument
 	nameToInstrument = tNameToInstrument
 	s.Unlock()
+
+	return nil
+}
+
+// LoadMetadataFromJson retrieves the instrument metadata from URL.
+// Unless isForce, it's a no-op when metadata is already loaded, which is always the case since the embedded snapshot.
+//
+// Deprecated: use Refresh
+func LoadMetadataFromJson(isForce bool) {
+	if !isForce {
+		ensureLoaded()
+		return
+	}
+
+	if err := Refresh(context.Background(), RefreshOptions{}); err != nil {
+		slog.Warn(
+			"Failed to retrieve dukas instrument",
+			slog.String("url", URL),
+			slog.Any("error", err),
+		)
+	}
 }
 
 func jsonToMetadata(code string, instrument Instrument) *Metadata {
+	decimalFactor := float64(instrument.DecimalFactor)
+	group, base, quote := classify(instrument.Name)
+	digits := 0
+	pointSize := 0.0
+	if decimalFactor > 0 {
+		digits = int(math.Round(math.Log10(decimalFactor)))
+		pointSize = 1 / decimalFactor
+	}
+
 	return &Metadata{
-		code:              strings.ToUpper(code),
-		name:              instrument.Name,
-		description:       instrument.Description,
-		minStartDate:      instrument.StartDayForMinuteCandles,
-		decimalFactor:     float64(instrument.DecimalFactor),
-		minStartDateDaily: instrument.StartYearForDailyCandles,
+		code:               strings.ToUpper(code),
+		name:               instrument.Name,
+		description:        instrument.Description,
+		minStartDate:       instrument.StartDayForMinuteCandles,
+		decimalFactor:      decimalFactor,
+		minStartDateDaily:  instrument.StartYearForDailyCandles,
+		minStartDateHourly: instrument.StartMonthForHourlyCandles,
+		startHourForTicks:  instrument.StartHourForTicks,
+
+		baseCurrency:  base,
+		quoteCurrency: quote,
+		digits:        digits,
+		pointSize:     pointSize,
+		pipSize:       pipSize(group, digits, pointSize),
+		group:         group,
+		priceFormat:   "%." + strconv.Itoa(digits) + "f",
 	}
 }
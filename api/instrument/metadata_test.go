@@ -1,8 +1,14 @@
 package instrument
 
 import (
+	"bytes"
+	"context"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetMetadata_happyPath(t *testing.T) {
@@ -43,3 +49,111 @@ func TestMetadata_DiffInPips(t *testing.T) {
 	diff := gold.DiffInPips("2352.68", "2354.90")
 	assert.Equal(t, "2220", diff, "diff")
 }
+
+const refreshedJson = `{"eurusd":{"name":"EUR/USD","description":"Euro vs US Dollar","decimalFactor":100000,"startHourForTicks":"2003-05-04T21:00:00.000Z"},
+"newusd":{"name":"NEW/USD","description":"New vs US Dollar","decimalFactor":100000,"startHourForTicks":"2024-01-01T00:00:00.000Z"}}`
+
+func restoreSnapshot(t *testing.T) {
+	t.Cleanup(func() {
+		assert.NoError(t, LoadMetadataFromReader(bytes.NewReader(snapshot)))
+	})
+}
+
+func TestLoadMetadataFromReader(t *testing.T) {
+	restoreSnapshot(t)
+
+	assert.NoError(t, LoadMetadataFromReader(strings.NewReader(refreshedJson)))
+	assert.NotNil(t, GetMetadata("NEWUSD"))
+	assert.Nil(t, GetMetadata("GBPJPY"))
+
+	assert.Error(t, LoadMetadataFromReader(strings.NewReader("{")))
+	assert.NotNil(t, GetMetadata("NEWUSD"), "invalid json keeps the current metadata")
+}
+
+func TestRefresh(t *testing.T) {
+	restoreSnapshot(t)
+
+	requests, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(refreshedJson))
+	}))
+	t.Cleanup(server.Close)
+
+	cacheDir := t.TempDir()
+	opts := RefreshOptions{URL: server.URL, CacheDir: cacheDir, MaxAge: time.Hour}
+	assert.NoError(t, Refresh(context.Background(), opts))
+	assert.NotNil(t, GetMetadata("NEWUSD"))
+	assert.Equal(t, 1, requests)
+
+	// Younger than max age, no request
+	assert.NoError(t, Refresh(context.Background(), opts))
+	assert.Equal(t, 1, requests)
+
+	// Revalidated with the ETag
+	opts.MaxAge = 0
+	assert.NoError(t, Refresh(context.Background(), opts))
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, notModified)
+	assert.NotNil(t, GetMetadata("NEWUSD"))
+
+	// Unreachable, falls back on the cache
+	server.Close()
+	assert.NoError(t, LoadMetadataFromReader(bytes.NewReader(snapshot)))
+	assert.NoError(t, Refresh(context.Background(), opts))
+	assert.NotNil(t, GetMetadata("NEWUSD"))
+}
+
+func TestSnapshot_Offline(t *testing.T) {
+	for _, code := range []string{"EURUSD", "GBPJPY", "XAUUSD", "USA500IDXUSD", "BTCUSD"} {
+		assert.NotNil(t, GetMetadata(code), code)
+	}
+}
+
+func TestMetadata_Classification(t *testing.T) {
+	tests := []struct {
+		code          string
+		group         Group
+		baseCurrency  string
+		quoteCurrency string
+		digits        int
+		pointSize     float64
+		pipSize       float64
+	}{
+		{"EURUSD", GroupFxMajor, "EUR", "USD", 5, 0.00001, 0.0001},
+		{"USDJPY", GroupFxMajor, "USD", "JPY", 3, 0.001, 0.01},
+		{"GBPJPY", GroupFxMinor, "GBP", "JPY", 3, 0.001, 0.01},
+		{"EURNOK", GroupFxExotic, "EUR", "NOK", 5, 0.00001, 0.0001},
+		{"XAUUSD", GroupMetals, "XAU", "USD", 3, 0.001, 0.01},
+		{"USA500IDXUSD", GroupIndices, "", "USD", 3, 0.001, 0.001},
+		{"BRENTCMDUSD", GroupCommodities, "", "USD", 3, 0.001, 0.001},
+		{"BTCUSD", GroupCrypto, "BTC", "USD", 1, 0.1, 0.1},
+		{"AUSUSD", GroupStocks, "", "USD", 3, 0.001, 0.001},
+	}
+	for _, test := range tests {
+		t.Run(test.code, func(t *testing.T) {
+			m := GetMetadata(test.code)
+			if !assert.NotNil(t, m) {
+				return
+			}
+			assert.Equal(t, test.group, m.Group())
+			assert.Equal(t, test.baseCurrency, m.BaseCurrency())
+			assert.Equal(t, test.quoteCurrency, m.QuoteCurrency())
+			assert.Equal(t, test.digits, m.Digits())
+			assert.InDelta(t, test.pointSize, m.PointSize(), 1e-12)
+			assert.InDelta(t, test.pipSize, m.PipSize(), 1e-12)
+		})
+	}
+}
+
+func TestMetadata_StartHourForTicks(t *testing.T) {
+	m := GetMetadata("EURUSD")
+	assert.Equal(t, time.Date(2003, time.May, 4, 21, 0, 0, 0, time.UTC), m.StartHourForTicks().UTC())
+	assert.Equal(t, time.Date(2003, time.May, 1, 0, 0, 0, 0, time.UTC), m.MinStartDateHourly().UTC())
+}
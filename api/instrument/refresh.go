@@ -0,0 +1,121 @@
+package instrument
+
+import (
+	"bytes"
+	"context"
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	refreshCacheFileName = "instrument-meta-data.json"
+	refreshETagExt       = ".etag"
+)
+
+// RefreshOptions configures the retrieval of the latest instrument metadata
+type RefreshOptions struct {
+	// URL of the instrument metadata json, defaults to URL
+	URL string
+	// CacheDir keeps the retrieved json and its ETag. No cache is kept if blank.
+	CacheDir string
+	// MaxAge of the cached json before it's revalidated with URL. 0 always revalidates.
+	MaxAge time.Duration
+}
+
+// Refresh replaces the embedded instrument metadata with the latest one published at URL.
+// With a cache dir, the cached json is used while younger than MaxAge, afterward it is revalidated with its ETag.
+// The cached json is used when URL can't be reached, if there's no cache the current metadata is kept.
+func Refresh(ctx context.Context, opts RefreshOptions) error {
+	url := opts.URL
+	if url == "" {
+		url = URL
+	}
+
+	var cachePath, etag string
+	isCached := false
+	if opts.CacheDir != "" {
+		cachePath = filepath.Join(opts.CacheDir, refreshCacheFileName)
+		if info, err := os.Stat(cachePath); err == nil {
+			isCached = true
+			if opts.MaxAge > 0 && time.Since(info.ModTime()) < opts.MaxAge {
+				return LoadMetadataFromFile(cachePath)
+			}
+			if b, err := os.ReadFile(cachePath + refreshETagExt); err == nil {
+				etag = strings.TrimSpace(string(b))
+			}
+		}
+	}
+
+	req := resty.New().R().SetContext(ctx)
+	if etag != "" {
+		req.SetHeader("If-None-Match", etag)
+	}
+	resp, err := req.Get(url)
+	if err == nil && resp.StatusCode() != http.StatusOK && resp.StatusCode() != http.StatusNotModified {
+		err = errors.Errorf("unexpected http status [%d]", resp.StatusCode())
+	}
+	if err != nil {
+		err = errors.Wrap(err, "failed to retrieve dukas instrument from ["+url+"]")
+		if !isCached {
+			return err
+		}
+
+		slog.Warn("Using cached instrument metadata",
+			slog.String("path", cachePath),
+			slog.Any("error", err),
+		)
+		return LoadMetadataFromFile(cachePath)
+	}
+
+	if resp.StatusCode() == http.StatusNotModified && isCached {
+		now := time.Now()
+		_ = os.Chtimes(cachePath, now, now)
+
+		return LoadMetadataFromFile(cachePath)
+	}
+
+	body := resp.Body()
+	if err = LoadMetadataFromReader(bytes.NewReader(body)); err != nil {
+		return err
+	}
+
+	if cachePath != "" {
+		if err = writeRefreshCache(cachePath, body, resp.Header().Get("ETag")); err != nil {
+			slog.Warn("Failed to cache instrument metadata",
+				slog.String("path", cachePath),
+				slog.Any("error", err),
+			)
+		}
+	}
+
+	return nil
+}
+
+func writeRefreshCache(cachePath string, body []byte, etag string) error {
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+
+	// Write then rename, so a concurrent reader never sees a partial json
+	tempPath := cachePath + ".tmp"
+	if err := os.WriteFile(tempPath, body, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, cachePath); err != nil {
+		return err
+	}
+
+	etagPath := cachePath + refreshETagExt
+	if etag == "" {
+		_ = os.Remove(etagPath)
+		return nil
+	}
+
+	return os.WriteFile(etagPath, []byte(etag), 0644)
+}
@@ -0,0 +1,118 @@
+package instrument
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Filter instruments, blank fields are ignored
+type Filter struct {
+	// Query matches case-insensitively the code, name or description
+	Query string
+	Group Group
+	// Currency matches either the base or the quote currency
+	Currency string
+	// AvailableAt matches instruments with tick data at that time
+	AvailableAt time.Time
+}
+
+// Match returns true if the instrument matches all filter fields
+func (f Filter) Match(m *Metadata) bool {
+	if m == nil {
+		return false
+	}
+	if f.Query != "" && queryRank(m, strings.ToUpper(f.Query)) < 0 {
+		return false
+	}
+	if f.Group != "" && m.Group() != f.Group {
+		return false
+	}
+	if f.Currency != "" {
+		currency := strings.ToUpper(f.Currency)
+		if m.BaseCurrency() != currency && m.QuoteCurrency() != currency {
+			return false
+		}
+	}
+	if !f.AvailableAt.IsZero() {
+		start := m.StartHourForTicks()
+		if start.IsZero() || start.After(f.AvailableAt) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// All returns every known instrument sorted by code
+func All() []*Metadata {
+	ensureLoaded()
+
+	s.RLock()
+	r := make([]*Metadata, 0, len(codeToInstrument))
+	for _, m := range codeToInstrument {
+		r = append(r, m)
+	}
+	s.RUnlock()
+
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Code() < r[j].Code()
+	})
+
+	return r
+}
+
+// Search instruments by code, name or description. Exact code matches come first, then code prefixes.
+func Search(query string) []*Metadata {
+	return Find(Filter{Query: query})
+}
+
+// Find returns the instruments matching the filter. With a query, the most relevant come first.
+func Find(filter Filter) []*Metadata {
+	r := make([]*Metadata, 0)
+	for _, m := range All() {
+		if filter.Match(m) {
+			r = append(r, m)
+		}
+	}
+
+	if filter.Query != "" {
+		query := strings.ToUpper(filter.Query)
+		sort.SliceStable(r, func(i, j int) bool {
+			return queryRank(r[i], query) < queryRank(r[j], query)
+		})
+	}
+
+	return r
+}
+
+// queryRank returns the relevance of the instrument for the upper case query, lower is better. -1 if not matching
+func queryRank(m *Metadata, query string) int {
+	code := m.Code()
+	switch {
+	case code == query:
+		return 0
+	case strings.HasPrefix(code, query):
+		return 1
+	case strings.Contains(code, query):
+		return 2
+	case strings.Contains(strings.ToUpper(m.Name()), query):
+		return 3
+	case strings.Contains(strings.ToUpper(m.Description()), query):
+		return 4
+	default:
+		return -1
+	}
+}
+
+// ParseGroup returns the group with the given name, false if unknown
+func ParseGroup(name string) (Group, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, g := range Groups {
+		if string(g) == name {
+			return g, true
+		}
+	}
+
+	return "", false
+}
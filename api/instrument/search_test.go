@@ -0,0 +1,68 @@
+package instrument
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func codes(metadata []*Metadata) []string {
+	r := make([]string, 0, len(metadata))
+	for _, m := range metadata {
+		r = append(r, m.Code())
+	}
+
+	return r
+}
+
+func TestAll(t *testing.T) {
+	all := All()
+	assert.NotEmpty(t, all)
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].Code(), all[i].Code())
+	}
+	assert.Subset(t, codes(all), []string{"EURUSD", "XAUUSD"})
+}
+
+func TestSearch(t *testing.T) {
+	r := codes(Search("usa500"))
+	if assert.NotEmpty(t, r) {
+		assert.Equal(t, "USA500IDXUSD", r[0])
+	}
+
+	r = codes(Search("eurusd"))
+	if assert.NotEmpty(t, r) {
+		assert.Equal(t, "EURUSD", r[0])
+	}
+
+	assert.Contains(t, codes(Search("bitcoin")), "BTCUSD")
+
+	assert.Empty(t, Search("NOT_EXISTENT"))
+}
+
+func TestFind(t *testing.T) {
+	filters := []struct {
+		filter   Filter
+		expected []string
+	}{
+		{Filter{Group: GroupIndices, Currency: "usd"}, []string{"USA30IDXUSD", "USA500IDXUSD", "USATECHIDXUSD"}},
+		{Filter{Group: GroupCrypto, AvailableAt: time.Date(2017, time.June, 1, 0, 0, 0, 0, time.UTC)}, []string{"BTCUSD"}},
+		{Filter{Query: "JPY", Currency: "NZD"}, []string{"NZDJPY"}},
+	}
+	for _, f := range filters {
+		r := Find(f.filter)
+		assert.Subset(t, codes(r), f.expected, f.filter)
+		for _, m := range r {
+			assert.True(t, f.filter.Match(m), m.Code())
+		}
+	}
+}
+
+func TestParseGroup(t *testing.T) {
+	g, ok := ParseGroup("FX_Major")
+	assert.True(t, ok)
+	assert.Equal(t, GroupFxMajor, g)
+
+	_, ok = ParseGroup("bonds")
+	assert.False(t, ok)
+}
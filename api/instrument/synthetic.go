@@ -0,0 +1,199 @@
+package instrument
+
+import (
+	"github.com/pkg/errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// Operator combining the legs of a synthetic instrument
+type Operator string
+
+const (
+	Multiply Operator = "*"
+	Divide   Operator = "/"
+)
+
+// Synthetic instrument priced from the time merged ticks of two instruments, i.e. EURNOK = EURUSD * USDNOK
+type Synthetic struct {
+	A        *Metadata
+	Operator Operator
+	B        *Metadata
+
+	decimalFactor float64
+}
+
+func (s Synthetic) String() string {
+	return s.A.Code() + string(s.Operator) + s.B.Code()
+}
+
+// Quote combines the legs quotes on the worst side, the bid is rounded down and the ask up to the point size.
+// Returns zeros if a divisor quote is zero.
+func (s Synthetic) Quote(aBid, aAsk, bBid, bAsk float64) (bid, ask float64) {
+	if s.Operator == Divide {
+		if bAsk == 0 || bBid == 0 {
+			return 0, 0
+		}
+		bid, ask = aBid/bAsk, aAsk/bBid
+	} else {
+		bid, ask = aBid*bBid, aAsk*bAsk
+	}
+
+	// The epsilon keeps exact prices like 1.1 * 10 from being rounded a point away
+	const epsilon = 1e-6
+	return math.Floor(bid*s.decimalFactor+epsilon) / s.decimalFactor,
+		math.Ceil(ask*s.decimalFactor-epsilon) / s.decimalFactor
+}
+
+// Synthetic returns the legs of a synthetic instrument, nil otherwise
+func (m *Metadata) Synthetic() *Synthetic {
+	if m == nil {
+		return nil
+	}
+
+	return m.synthetic
+}
+
+// NewSynthetic defines the instrument code = a operator b without registering it.
+// With Multiply the quote currency of a must be the base currency of b, i.e. EURUSD * USDNOK,
+// with Divide a and b must have the same quote currency, i.e. EURUSD / GBPUSD.
+// The decimal factor is the one of the instrument with the same code or name if any,
+// otherwise the one of b with Multiply and the largest of the legs with Divide.
+func NewSynthetic(code string, a *Metadata, operator Operator, b *Metadata) (*Metadata, error) {
+	ensureLoaded()
+
+	s.RLock()
+	defer s.RUnlock()
+
+	return newSynthetic(code, a, operator, b, codeToInstrument, nameToInstrument)
+}
+
+func newSynthetic(code string, a *Metadata, operator Operator, b *Metadata, codeToInstrument, nameToInstrument map[string]*Metadata) (*Metadata, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" || a == nil || b == nil {
+		return nil, errors.Errorf("invalid synthetic instrument [%s], the code and both legs are required", code)
+	}
+
+	left, _, _ := strings.Cut(a.Name(), "/")
+	var quote string
+	var decimalFactor float64
+	switch operator {
+	case Multiply:
+		if a.QuoteCurrency() == "" || a.QuoteCurrency() != b.BaseCurrency() {
+			return nil, errors.Errorf("invalid synthetic instrument [%s], [%s] isn't quoted in the base currency of [%s]", code, a.Code(), b.Code())
+		}
+		quote = b.QuoteCurrency()
+		decimalFactor = b.DecimalFactor()
+	case Divide:
+		if a.QuoteCurrency() == "" || a.QuoteCurrency() != b.QuoteCurrency() || b.BaseCurrency() == "" {
+			return nil, errors.Errorf("invalid synthetic instrument [%s], [%s] and [%s] aren't quoted in the same currency", code, a.Code(), b.Code())
+		}
+		quote = b.BaseCurrency()
+		decimalFactor = math.Max(a.DecimalFactor(), b.DecimalFactor())
+	default:
+		return nil, errors.Errorf("invalid synthetic instrument [%s], unknown operator [%s]", code, operator)
+	}
+
+	name := left + "/" + quote
+	if m := codeToInstrument[code]; m != nil {
+		decimalFactor = m.DecimalFactor()
+	} else if m = nameToInstrument[name]; m != nil {
+		decimalFactor = m.DecimalFactor()
+	}
+	m := jsonToMetadata(code, Instrument{
+		Name:                       name,
+		Description:                "Synthetic " + a.Name() + " " + string(operator) + " " + b.Name(),
+		DecimalFactor:              int(decimalFactor),
+		StartHourForTicks:          later(a.StartHourForTicks(), b.StartHourForTicks()),
+		StartDayForMinuteCandles:   later(a.MinStartDate(), b.MinStartDate()),
+		StartMonthForHourlyCandles: later(a.MinStartDateHourly(), b.MinStartDateHourly()),
+		StartYearForDailyCandles:   later(a.MinStartDateDaily(), b.MinStartDateDaily()),
+	})
+	m.synthetic = &Synthetic{A: a, Operator: operator, B: b, decimalFactor: decimalFactor}
+
+	return m, nil
+}
+
+// ParseSynthetic parses a definition like EURNOK=EURUSD*USDNOK or EURGBP=EURUSD/GBPUSD
+func ParseSynthetic(definition string) (code, a string, operator Operator, b string, err error) {
+	code, expression, ok := strings.Cut(definition, "=")
+	if ok {
+		i := strings.IndexAny(expression, string(Multiply)+string(Divide))
+		if i >= 0 {
+			a, operator, b = expression[:i], Operator(expression[i:i+1]), expression[i+1:]
+		}
+	}
+
+	code, a, b = strings.ToUpper(strings.TrimSpace(code)), strings.ToUpper(strings.TrimSpace(a)), strings.ToUpper(strings.TrimSpace(b))
+	if code == "" || a == "" || b == "" {
+		return "", "", "", "", errors.Errorf("invalid synthetic instrument [%s], expected a definition like EURNOK=EURUSD*USDNOK", definition)
+	}
+
+	return code, a, operator, b, nil
+}
+
+// syntheticDefinition registered by RegisterSynthetic, defined again once the metadata is reloaded
+type syntheticDefinition struct {
+	code, a, b string
+	operator   Operator
+}
+
+var synthetics []syntheticDefinition
+
+// RegisterSynthetic parses the definition, see ParseSynthetic, and registers the synthetic instrument.
+// It's then returned by GetMetadata like the dukascopy instruments, replacing the one with the same code.
+// The legs might be registered synthetic instruments.
+func RegisterSynthetic(definition string) (*Metadata, error) {
+	code, a, operator, b, err := ParseSynthetic(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	ensureLoaded()
+
+	s.Lock()
+	defer s.Unlock()
+
+	d := syntheticDefinition{code: code, a: a, b: b, operator: operator}
+	m, err := d.define(codeToInstrument, nameToInstrument)
+	if err != nil {
+		return nil, err
+	}
+
+	isRedefined := false
+	for i, existing := range synthetics {
+		if existing.code == code {
+			synthetics[i], isRedefined = d, true
+		}
+	}
+	if !isRedefined {
+		synthetics = append(synthetics, d)
+	}
+	register(codeToInstrument, nameToInstrument, m)
+
+	return m, nil
+}
+
+// define the synthetic instrument from the legs of the instruments
+func (d syntheticDefinition) define(codeToInstrument, nameToInstrument map[string]*Metadata) (*Metadata, error) {
+	a, b := codeToInstrument[d.a], codeToInstrument[d.b]
+	if a == nil || b == nil {
+		return nil, errors.Errorf("invalid synthetic instrument [%s], unknown leg [%s] or [%s]", d.code, d.a, d.b)
+	}
+
+	return newSynthetic(d.code, a, d.operator, b, codeToInstrument, nameToInstrument)
+}
+
+func register(codeToInstrument, nameToInstrument map[string]*Metadata, m *Metadata) {
+	codeToInstrument[m.Code()] = m
+	nameToInstrument[m.Name()] = m
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,103 @@
+package instrument
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewSynthetic(t *testing.T) {
+	m, err := NewSynthetic("eurnok", GetMetadata("EURUSD"), Multiply, GetMetadata("USDNOK"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "EURNOK", m.Code())
+	assert.Equal(t, "EUR/NOK", m.Name())
+	assert.Equal(t, "EUR", m.BaseCurrency())
+	assert.Equal(t, "NOK", m.QuoteCurrency())
+	assert.Equal(t, float64(100000), m.DecimalFactor())
+	assert.Equal(t, GetMetadata("USDNOK").StartHourForTicks(), m.StartHourForTicks(), "the later leg start")
+	assert.Equal(t, "EURUSD*USDNOK", m.Synthetic().String())
+	assert.Nil(t, GetMetadata("EURUSD").Synthetic())
+
+	// The bid of both legs is the worst synthetic bid, the ask of both legs the worst ask
+	bid, ask := m.Synthetic().Quote(1.10000, 1.10002, 10.00000, 10.00050)
+	assert.Equal(t, 11.0, bid)
+	assert.Equal(t, 11.00076, ask, "11.00075001 rounded up")
+
+	// Unknown code and name, quoted in JPY like the second leg
+	m, err = NewSynthetic("EURJPYX", GetMetadata("EURUSD"), Multiply, GetMetadata("USDJPY"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, float64(1000), m.DecimalFactor())
+	}
+
+	m, err = NewSynthetic("GBPEUR", GetMetadata("GBPUSD"), Divide, GetMetadata("EURUSD"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "GBP/EUR", m.Name())
+		// Divided by the ask on the bid side, rounded down, and by the bid on the ask side, rounded up
+		bid, ask = m.Synthetic().Quote(1.25000, 1.25002, 1.10000, 1.10002)
+		assert.Equal(t, 1.13634, bid)
+		assert.Equal(t, 1.13639, ask)
+	}
+}
+
+func TestNewSynthetic_invalid(t *testing.T) {
+	_, err := NewSynthetic("EURNOK", GetMetadata("EURUSD"), Multiply, GetMetadata("EURNOK"))
+	assert.Error(t, err, "EURUSD isn't quoted in EUR")
+
+	_, err = NewSynthetic("EURNOK", GetMetadata("EURUSD"), Divide, GetMetadata("USDNOK"))
+	assert.Error(t, err, "not quoted in the same currency")
+
+	_, err = NewSynthetic("EURNOK", GetMetadata("EURUSD"), "+", GetMetadata("USDNOK"))
+	assert.Error(t, err)
+
+	_, err = NewSynthetic("EURNOK", nil, Multiply, GetMetadata("USDNOK"))
+	assert.Error(t, err)
+}
+
+func TestParseSynthetic(t *testing.T) {
+	code, a, operator, b, err := ParseSynthetic(" eurgbp = EURUSD / gbpusd ")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "EURGBP", code)
+		assert.Equal(t, "EURUSD", a)
+		assert.Equal(t, Divide, operator)
+		assert.Equal(t, "GBPUSD", b)
+	}
+
+	for _, definition := range []string{"", "EURNOK", "EURNOK=EURUSD", "=EURUSD*USDNOK", "EURNOK=*USDNOK"} {
+		_, _, _, _, err = ParseSynthetic(definition)
+		assert.Error(t, err, definition)
+	}
+}
+
+func TestRegisterSynthetic(t *testing.T) {
+	restoreSnapshot(t)
+	t.Cleanup(func() { synthetics = nil })
+
+	m, err := RegisterSynthetic("XEURNOK=EURUSD*USDNOK")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Same(t, m, GetMetadata("xeurnok"))
+
+	// Synthetic legs
+	_, err = RegisterSynthetic("XEURUSD=XEURNOK/EURUSD")
+	assert.Error(t, err, "EURNOK and EURUSD aren't quoted in the same currency")
+	chained, err := RegisterSynthetic("XEURUSD=XEURNOK/USDNOK")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "EUR/USD", chained.Name())
+		assert.Same(t, m, chained.Synthetic().A)
+	}
+
+	_, err = RegisterSynthetic("XEURNOK=EURUSD*UNKNOWN")
+	assert.Error(t, err)
+	assert.Same(t, m, GetMetadata("XEURNOK"), "a failed registration keeps the existing one")
+
+	// Reloading the metadata defines the synthetic instruments from the new legs
+	assert.NoError(t, LoadMetadataFromReader(bytes.NewReader(snapshot)))
+	reloaded := GetMetadata("XEURUSD")
+	if assert.NotNil(t, reloaded) {
+		assert.NotSame(t, m, reloaded.Synthetic().A)
+		assert.Same(t, GetMetadata("XEURNOK"), reloaded.Synthetic().A)
+	}
+}
@@ -0,0 +1,179 @@
+// Package quote answers what the bid and ask were at given times, the last tick at or before each time
+package quote
+
+import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/internal/bi5"
+	"github.com/edward-yakop/go-duka/internal/misc"
+	"github.com/pkg/errors"
+	"sort"
+	"time"
+)
+
+// DefaultLookback covers a weekend and a bank holiday
+const DefaultLookback = 96 * time.Hour
+
+// Snapshot is the quote at Time
+type Snapshot struct {
+	// Time requested
+	Time time.Time
+	// Tick is the last tick at or before Time, nil if there's none within the lookback
+	Tick *tickdata.TickData
+	// Staleness is the duration between the tick and Time
+	Staleness time.Duration
+}
+
+// Found returns whether a tick was found within the lookback
+func (s Snapshot) Found() bool {
+	return s.Tick != nil
+}
+
+// Lookup searches the quotes of an instrument, hours are read from the folder cache and downloaded when missing
+type Lookup struct {
+	instrument         *instrument.Metadata
+	downloadFolderPath string
+	opts               []datafeed.Option
+	lookback           time.Duration
+}
+
+func NewLookup(instrument *instrument.Metadata, downloadFolderPath string, opts ...datafeed.Option) *Lookup {
+	return &Lookup{
+		instrument:         instrument,
+		downloadFolderPath: downloadFolderPath,
+		opts:               opts,
+		lookback:           DefaultLookback,
+	}
+}
+
+// SetLookback sets how far back a tick is searched (default DefaultLookback)
+func (l *Lookup) SetLookback(lookback time.Duration) *Lookup {
+	if lookback < 0 {
+		lookback = 0
+	}
+	l.lookback = lookback
+
+	return l
+}
+
+// Quote is Quotes of a single time
+func Quote(instrument *instrument.Metadata, t time.Time, downloadFolderPath string, opts ...datafeed.Option) (Snapshot, error) {
+	snapshots, err := NewLookup(instrument, downloadFolderPath, opts...).QuotesContext(context.Background(), []time.Time{t})
+	if err != nil {
+		return Snapshot{Time: t}, err
+	}
+
+	return snapshots[0], nil
+}
+
+// Quotes returns the snapshots of the times, in the same order, with the default lookback
+func Quotes(instrument *instrument.Metadata, times []time.Time, downloadFolderPath string, opts ...datafeed.Option) ([]Snapshot, error) {
+	return NewLookup(instrument, downloadFolderPath, opts...).QuotesContext(context.Background(), times)
+}
+
+func (l *Lookup) Quote(t time.Time) (Snapshot, error) {
+	snapshots, err := l.QuotesContext(context.Background(), []time.Time{t})
+	if err != nil {
+		return Snapshot{Time: t}, err
+	}
+
+	return snapshots[0], nil
+}
+
+func (l *Lookup) Quotes(times []time.Time) ([]Snapshot, error) {
+	return l.QuotesContext(context.Background(), times)
+}
+
+// QuotesContext returns the snapshots of the times, in the same order. Hours without ticks, like the weekend ones,
+// are searched backward up to the lookback, a failed download is returned. Times are looked up chronologically
+// so each hour is decoded once.
+func (l *Lookup) QuotesContext(ctx context.Context, times []time.Time) ([]Snapshot, error) {
+	order := make([]int, len(times))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return times[order[i]].Before(times[order[j]])
+	})
+
+	dataStart := misc.ToHourUTC(datafeed.DataStart(l.instrument))
+	hours := make(map[time.Time][]*tickdata.TickData)
+	snapshots := make([]Snapshot, len(times))
+	for _, i := range order {
+		at := times[i]
+		snapshots[i].Time = at
+
+		// Hours before the lookback aren't needed by the following times
+		oldest := misc.ToHourUTC(at.Add(-l.lookback))
+		for dayHour := range hours {
+			if dayHour.Before(oldest) {
+				delete(hours, dayHour)
+			}
+		}
+		if oldest.Before(dataStart) {
+			oldest = dataStart
+		}
+
+		tick, err := l.search(ctx, hours, at, oldest)
+		if err != nil {
+			return nil, err
+		}
+		if tick != nil {
+			snapshots[i].Tick = tick
+			snapshots[i].Staleness = at.Sub(tick.UTC())
+		}
+	}
+
+	return snapshots, nil
+}
+
+// search the last tick at or before at, down to the oldest hour
+func (l *Lookup) search(ctx context.Context, hours map[time.Time][]*tickdata.TickData, at time.Time, oldest time.Time) (*tickdata.TickData, error) {
+	earliest := at.Add(-l.lookback)
+	for dayHour := misc.ToHourUTC(at); !dayHour.Before(oldest); dayHour = dayHour.Add(-time.Hour) {
+		ticks, isLoaded := hours[dayHour]
+		if !isLoaded {
+			var err error
+			if ticks, err = l.load(ctx, dayHour); err != nil {
+				return nil, err
+			}
+			hours[dayHour] = ticks
+		}
+
+		i := sort.Search(len(ticks), func(i int) bool {
+			return ticks[i].UTC().After(at)
+		}) - 1
+		if i < 0 {
+			continue
+		}
+		if ticks[i].UTC().Before(earliest) {
+			return nil, nil
+		}
+
+		return ticks[i], nil
+	}
+
+	return nil, nil
+}
+
+// load the ticks of the hour. Hours without tick data are marked by the download and have no ticks,
+// a failed download is returned rather than searching older hours.
+func (l *Lookup) load(ctx context.Context, dayHour time.Time) ([]*tickdata.TickData, error) {
+	bi := bi5.New(dayHour, l.instrument, l.downloadFolderPath, l.opts...)
+	derr := bi.DownloadContext(ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if derr != nil {
+		return nil, errors.Wrapf(derr, "failed to download [%s] ticks of [%s]", l.instrument.Code(), dayHour.Format("2006-01-02:15H"))
+	}
+
+	ticks, err := bi.Ticks()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read [%s] ticks of [%s]", l.instrument.Code(), dayHour.Format("2006-01-02:15H"))
+	}
+
+	return ticks, nil
+}
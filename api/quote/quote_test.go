@@ -0,0 +1,84 @@
+package quote
+
+import (
+	"github.com/edward-yakop/go-duka/api/datafeed"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	_ "time/tzdata" // Ensure that custom timezone is included
+)
+
+func TestQuotes(t *testing.T) {
+	mirror := t.TempDir()
+	friday := time.Date(2020, time.November, 6, 21, 0, 0, 0, time.UTC)
+	monday := time.Date(2020, time.November, 9, 10, 0, 0, 0, time.UTC)
+	for _, h := range []time.Time{friday, monday} {
+		bi5test.WriteMirror(t, mirror, "EURUSD", h, bi5test.Encode(t, h, 100000,
+			bi5test.Tick("EURUSD", h.Add(time.Second), 1.18502, 1.18500),
+			bi5test.Tick("EURUSD", h.Add(time.Minute), 1.18512, 1.18510),
+		))
+	}
+
+	athens, _ := time.LoadLocation("Europe/Athens")
+	times := []time.Time{
+		monday.Add(30 * time.Minute).In(athens),
+		friday.Add(30 * time.Second),
+		time.Date(2020, time.November, 8, 12, 0, 0, 0, time.UTC), // Weekend
+		monday.Add(time.Minute),
+	}
+
+	folder := t.TempDir()
+	snapshots, err := Quotes(instrument.GetMetadata("EURUSD"), times, folder, bi5test.FileSource(t, mirror))
+	if !assert.NoError(t, err) || !assert.Len(t, snapshots, 4) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, times[0], snapshots[0].Time)
+	if assert.True(t, snapshots[0].Found()) {
+		assert.Equal(t, 1.18510, snapshots[0].Tick.Bid)
+		assert.Equal(t, 29*time.Minute, snapshots[0].Staleness)
+	}
+	if assert.True(t, snapshots[1].Found()) {
+		assert.Equal(t, 1.18500, snapshots[1].Tick.Bid)
+		assert.Equal(t, 29*time.Second, snapshots[1].Staleness)
+	}
+	if assert.True(t, snapshots[2].Found()) {
+		assert.Equal(t, friday.Add(time.Minute), snapshots[2].Tick.UTC())
+	}
+	if assert.True(t, snapshots[3].Found()) {
+		assert.Equal(t, time.Duration(0), snapshots[3].Staleness)
+	}
+
+	// The lookback bounds the search, cached hours are read offline
+	lookup := NewLookup(instrument.GetMetadata("EURUSD"), folder, datafeed.WithOffline()).SetLookback(time.Hour)
+	snapshot, err := lookup.Quote(times[2])
+	assert.NoError(t, err)
+	assert.False(t, snapshot.Found())
+	snapshot, err = lookup.Quote(monday.Add(30 * time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, snapshot.Found())
+}
+
+func TestQuote_OfflineNotCached(t *testing.T) {
+	at := time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC)
+	_, err := Quote(instrument.GetMetadata("EURUSD"), at, t.TempDir(), datafeed.WithOffline())
+	var notCached *datafeed.ErrNotCached
+	assert.ErrorAs(t, err, &notCached)
+}
+
+func TestQuote_DownloadError(t *testing.T) {
+	mirror := t.TempDir()
+	at := time.Date(2021, time.March, 1, 10, 30, 0, 0, time.UTC)
+	previous := at.Add(-time.Hour).Truncate(time.Hour)
+	bi5test.WriteMirror(t, mirror, "EURUSD", previous, bi5test.Encode(t, previous, 100000,
+		bi5test.Tick("EURUSD", previous.Add(time.Second), 1.20002, 1.20000),
+	))
+	bi5test.WriteMirror(t, mirror, "EURUSD", at.Truncate(time.Hour), []byte("not lzma"))
+
+	// The corrupted hour fails the lookup instead of returning the previous hour tick
+	snapshot, err := Quote(instrument.GetMetadata("EURUSD"), at, t.TempDir(), bi5test.FileSource(t, mirror))
+	assert.Error(t, err)
+	assert.False(t, snapshot.Found())
+}
@@ -1,6 +1,7 @@
 package tickdata
 
 import (
+	"iter"
 	"time"
 )
 
@@ -13,3 +14,12 @@ type Day interface {
 	EachDay(it DayIterator)
 	EachTick(it TickIterator)
 }
+
+// DayTicks yields the ticks of the day, breaking out of the loop stops reading
+func DayTicks(d Day) iter.Seq2[*TickData, error] {
+	return func(yield func(*TickData, error) bool) {
+		d.EachTick(func(tick *TickData, err error) bool {
+			return yield(tick, err)
+		})
+	}
+}
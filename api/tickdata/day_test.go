@@ -0,0 +1,75 @@
+package tickdata_test
+
+import (
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
+	iTickdata "github.com/edward-yakop/go-duka/internal/tickdata"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// stoppedDay records whether its iteration was stopped
+type stoppedDay struct {
+	tickdata.Day
+	ticks     []*tickdata.TickData
+	isStopped bool
+}
+
+func (d *stoppedDay) EachTick(it tickdata.TickIterator) {
+	for _, tick := range d.ticks {
+		if !it(tick, nil) {
+			d.isStopped = true
+			return
+		}
+	}
+}
+
+func TestDayTicks(t *testing.T) {
+	day := time.Date(2021, time.January, 8, 0, 0, 0, 0, time.UTC)
+	hour10, hour11 := day.Add(10*time.Hour), day.Add(11*time.Hour)
+	mirror := t.TempDir()
+	bi5test.WriteMirror(t, mirror, "EURUSD", hour10, bi5test.Encode(t, hour10, 100000,
+		bi5test.Tick("EURUSD", hour10.Add(time.Second), 1.10002, 1.10000),
+		bi5test.Tick("EURUSD", hour10.Add(2*time.Second), 1.10012, 1.10010),
+	))
+	bi5test.WriteMirror(t, mirror, "EURUSD", hour11, bi5test.Encode(t, hour11, 100000,
+		bi5test.Tick("EURUSD", hour11.Add(time.Second), 1.10022, 1.10020),
+		bi5test.Tick("EURUSD", hour11.Add(2*time.Second), 1.10032, 1.10030),
+	))
+
+	d, err := iTickdata.FetchDay(instrument.GetMetadata("EURUSD"), day, t.TempDir(), bi5test.FileSource(t, mirror))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	var times []time.Time
+	for tick, err := range tickdata.DayTicks(d) {
+		if !assert.NoError(t, err) {
+			break
+		}
+		times = append(times, tick.UTC())
+	}
+	assert.Equal(t, []time.Time{hour10.Add(time.Second), hour10.Add(2 * time.Second), hour11.Add(time.Second), hour11.Add(2 * time.Second)}, times)
+
+	// Breaking out of the loop within an hour file
+	var bids []float64
+	for tick := range tickdata.DayTicks(d) {
+		bids = append(bids, tick.Bid)
+		if len(bids) == 3 {
+			break
+		}
+	}
+	assert.Equal(t, []float64{1.10000, 1.10010, 1.10020}, bids)
+
+	// The day iteration is stopped
+	stopped := &stoppedDay{ticks: []*tickdata.TickData{
+		bi5test.Tick("EURUSD", hour10, 1.10002, 1.10000),
+		bi5test.Tick("EURUSD", hour11, 1.10012, 1.10010),
+	}}
+	for range tickdata.DayTicks(stopped) {
+		break
+	}
+	assert.True(t, stopped.isStopped)
+}
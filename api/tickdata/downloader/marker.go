@@ -0,0 +1,29 @@
+package downloader
+
+import (
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/internal/bi5"
+	"time"
+)
+
+// Marker is a .notFound or .empty sentinel file written in place of an hour tick data
+type Marker = bi5.Marker
+
+// MarkerKind is either MarkerNotFound or MarkerEmpty
+type MarkerKind = bi5.MarkerKind
+
+const (
+	MarkerNotFound = bi5.MarkerNotFound
+	MarkerEmpty    = bi5.MarkerEmpty
+)
+
+// ListMarkers returns the markers of the instrument hours between from and to (both inclusive)
+func ListMarkers(folder string, instrument *instrument.Metadata, from, to time.Time) ([]Marker, error) {
+	return bi5.ListMarkers(folder, instrument.Code(), from, to)
+}
+
+// ClearMarkers removes the markers of the instrument hours between from and to (both inclusive),
+// so that those hours are downloaded again on next request
+func ClearMarkers(folder string, instrument *instrument.Metadata, from, to time.Time) ([]Marker, error) {
+	return bi5.ClearMarkers(folder, instrument.Code(), from, to)
+}
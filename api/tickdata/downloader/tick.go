@@ -1,17 +1,34 @@
 package downloader
 
 import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/internal/bi5"
 	"github.com/edward-yakop/go-duka/internal/misc"
+	"github.com/pkg/errors"
+	"sort"
+	"sync"
 	"time"
 )
 
+// DownloadListener is notified after each bi5 download. Calls are serialized, even when downloading
+// concurrently, and curr increases by one on each call.
 type DownloadListener func(instrumentCode string, dayHour time.Time, err error, curr, count int)
 
 type TickDownloader interface {
+	// Add queues the hours between from and to. A range starting before the instrument tick data is clamped,
+	// unless datafeed.WithStrictRange is set in which case DownloadContext returns *datafeed.ErrBeforeDataStart
+	// and Download passes it to the listener.
+	// The hours of a synthetic instrument are the ones of its legs.
 	Add(instrument *instrument.Metadata, from, to time.Time) TickDownloader
+	// SetConcurrency sets the maximum number of concurrent downloads across all instruments (default 1)
+	// and per instrument. A perInstrument value of 0 means it's only bounded by limit.
+	// Hours of an instrument are always started in chronological order.
+	SetConcurrency(limit, perInstrument int) TickDownloader
 	Download(DownloadListener)
+	// DownloadContext stops downloading once ctx is done and returns ctx.Err()
+	DownloadContext(ctx context.Context, listener DownloadListener) error
 	Count() int
 }
 
@@ -47,38 +64,119 @@ func (h hours) Size() int {
 	return len(h._hours)
 }
 
+// Sorted returns the hours in chronological order
+func (h hours) Sorted() []time.Time {
+	r := make([]time.Time, 0, len(h._hours))
+	for t := range h._hours {
+		r = append(r, t)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Before(r[j])
+	})
+
+	return r
+}
+
 var doNothingListener DownloadListener = func(symbol string, dayHour time.Time, err error, curr, count int) {
 	// Do nothing. This is a substitution when listener is passed as nil in Download
 }
 
-func (h *hours) Download(downloader *bi5.Downloader, symbol string, progress, count int, listener DownloadListener) int {
-	if listener == nil {
-		listener = doNothingListener
-	}
+// progress serializes listener notifications of concurrent downloads
+type progress struct {
+	lock     sync.Mutex
+	curr     int
+	count    int
+	listener DownloadListener
+}
 
-	for t := range h._hours {
-		err := downloader.Download(symbol, t)
-		progress++
-		listener(symbol, t, err, progress, count)
+func (p *progress) done(instrumentCode string, dayHour time.Time, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.curr++
+	p.listener(instrumentCode, dayHour, err, p.curr, p.count)
+}
+
+func (h *hours) Download(ctx context.Context, downloader *bi5.Downloader, perInstrument int, slots chan struct{}, p *progress) {
+	dayHours := make(chan time.Time)
+	go func() {
+		defer close(dayHours)
+		for _, t := range h.Sorted() {
+			select {
+			case dayHours <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < perInstrument; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range dayHours {
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					continue
+				}
+
+				err := downloader.DownloadContext(ctx, h.instrumentCode, t)
+				<-slots
+
+				if ctx.Err() == nil {
+					p.done(h.instrumentCode, t, err)
+				}
+			}
+		}()
 	}
-	return progress
+	wg.Wait()
 }
 
 type downloaderImpl struct {
-	instruments map[string]*hours
-	count       int
-	downloader  *bi5.Downloader
+	instruments   map[string]*hours
+	count         int
+	downloader    *bi5.Downloader
+	config        datafeed.Config
+	limit         int
+	perInstrument int
+	err           error
 }
 
-func NewTickDownloader(folder string) TickDownloader {
+func NewTickDownloader(folder string, opts ...datafeed.Option) TickDownloader {
 	return &downloaderImpl{
-		downloader:  bi5.NewDownloader(folder),
+		downloader:  bi5.NewDownloader(folder, opts...),
+		config:      datafeed.NewConfig(opts...),
 		instruments: make(map[string]*hours),
+		limit:       1,
 	}
 }
 
 func (d *downloaderImpl) Add(instrument *instrument.Metadata, from, to time.Time) TickDownloader {
-	instrumentCode := instrument.Code()
+	if to.Before(from) {
+		from, to = to, from
+	}
+
+	from, to, err := d.config.ClampRange(instrument, from, to)
+	if err != nil {
+		if d.err == nil {
+			d.err = err
+		}
+		return d
+	}
+	if from.After(to) {
+		return d
+	}
+	// Synthetic instruments are priced from the tick data of their legs
+	if synthetic := instrument.Synthetic(); synthetic != nil {
+		return d.Add(synthetic.A, from, to).Add(synthetic.B, from, to)
+	}
+
+	return d.add(instrument.Code(), from, to)
+}
+
+func (d *downloaderImpl) add(instrumentCode string, from, to time.Time) TickDownloader {
 	h, ok := d.instruments[instrumentCode]
 	if !ok {
 		h = newHours(instrumentCode)
@@ -91,13 +189,73 @@ func (d *downloaderImpl) Add(instrument *instrument.Metadata, from, to time.Time
 	return d
 }
 
+func (d *downloaderImpl) SetConcurrency(limit, perInstrument int) TickDownloader {
+	if limit < 1 {
+		limit = 1
+	}
+	if perInstrument < 0 {
+		perInstrument = 0
+	}
+	d.limit = limit
+	d.perInstrument = perInstrument
+
+	return d
+}
+
 func (d downloaderImpl) Count() int {
 	return d.count
 }
 
+// Download has no error result, an Add rejected by the strict range mode is passed to the listener instead
 func (d downloaderImpl) Download(listener DownloadListener) {
-	progress := 0
-	for s, i := range d.instruments {
-		progress = i.Download(d.downloader, s, progress, d.count, listener)
+	err := d.DownloadContext(context.Background(), listener)
+	if err == nil || listener == nil {
+		return
+	}
+
+	var beforeStart *datafeed.ErrBeforeDataStart
+	if errors.As(err, &beforeStart) {
+		listener(beforeStart.Symbol, beforeStart.Start, err, 0, d.count)
+	} else {
+		listener("", time.Time{}, err, 0, d.count)
+	}
+}
+
+func (d downloaderImpl) DownloadContext(ctx context.Context, listener DownloadListener) error {
+	if d.err != nil {
+		return d.err
+	}
+	if listener == nil {
+		listener = doNothingListener
+	}
+
+	perInstrument := d.perInstrument
+	if perInstrument == 0 || perInstrument > d.limit {
+		perInstrument = d.limit
+	}
+
+	p := &progress{count: d.count, listener: listener}
+	slots := make(chan struct{}, d.limit)
+
+	var wg sync.WaitGroup
+	for _, code := range d.instrumentCodes() {
+		wg.Add(1)
+		go func(h *hours) {
+			defer wg.Done()
+			h.Download(ctx, d.downloader, perInstrument, slots, p)
+		}(d.instruments[code])
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (d downloaderImpl) instrumentCodes() []string {
+	codes := make([]string, 0, len(d.instruments))
+	for code := range d.instruments {
+		codes = append(codes, code)
 	}
+	sort.Strings(codes)
+
+	return codes
 }
@@ -1,11 +1,17 @@
 package downloader
 
 import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/internal/bi5"
 	"github.com/edward-yakop/go-duka/internal/misc"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -41,3 +47,95 @@ func createEmptyDir(t *testing.T) string {
 	})
 	return dir
 }
+
+func TestDownloader_Concurrency(t *testing.T) {
+	var lock sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	instrumentInFlight, maxInstrumentInFlight := map[string]int{}, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")[0]
+		lock.Lock()
+		inFlight++
+		instrumentInFlight[code]++
+		maxInFlight = max(maxInFlight, inFlight)
+		maxInstrumentInFlight = max(maxInstrumentInFlight, instrumentInFlight[code])
+		lock.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		lock.Lock()
+		inFlight--
+		instrumentInFlight[code]--
+		lock.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	from := time.Date(2021, time.January, 8, 0, 0, 0, 0, time.UTC)
+	to := from.Add(5 * time.Hour)
+	d := NewTickDownloader(createEmptyDir(t), datafeed.WithSource(server.URL)).
+		SetConcurrency(4, 2).(*downloaderImpl)
+	for _, code := range []string{"EURUSD", "GBPUSD", "USDJPY"} {
+		d.add(code, from, to)
+	}
+
+	currs := make([]int, 0, d.Count())
+	err := d.DownloadContext(context.Background(), func(instrumentCode string, dayHour time.Time, err error, curr, count int) {
+		assert.NoError(t, err)
+		assert.Equal(t, 18, count)
+		currs = append(currs, curr)
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, currs, 18)
+	for i, curr := range currs {
+		assert.Equal(t, i+1, curr)
+	}
+	assert.LessOrEqual(t, maxInFlight, 4)
+	assert.LessOrEqual(t, maxInstrumentInFlight, 2)
+	assert.Greater(t, maxInFlight, 1)
+}
+
+func TestDownloader_ChronologicalOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	from := time.Date(2021, time.January, 8, 0, 0, 0, 0, time.UTC)
+	d := NewTickDownloader(createEmptyDir(t), datafeed.WithSource(server.URL)).
+		SetConcurrency(2, 1).(*downloaderImpl)
+	d.add("EURUSD", from, from.Add(10*time.Hour))
+	d.add("GBPUSD", from, from.Add(10*time.Hour))
+
+	last := map[string]time.Time{}
+	d.Download(func(instrumentCode string, dayHour time.Time, err error, curr, count int) {
+		assert.True(t, dayHour.After(last[instrumentCode]), "%s %s", instrumentCode, dayHour)
+		last[instrumentCode] = dayHour
+	})
+	assert.Len(t, last, 2)
+}
+
+func TestDownloader_RangeBeforeDataStart(t *testing.T) {
+	metadata := instrument.GetMetadata("EURUSD")
+	from := time.Date(1990, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2003, time.May, 4, 22, 0, 0, 0, time.UTC)
+
+	d := NewTickDownloader(createEmptyDir(t), datafeed.WithOffline()).
+		Add(metadata, from, to).
+		Add(metadata, from, from.Add(time.Hour))
+	assert.Equal(t, 2, d.Count())
+
+	d = NewTickDownloader(createEmptyDir(t), datafeed.WithOffline(), datafeed.WithStrictRange()).
+		Add(metadata, from, to)
+	var beforeStart *datafeed.ErrBeforeDataStart
+	assert.ErrorAs(t, d.DownloadContext(context.Background(), nil), &beforeStart)
+
+	// The legacy Download reports the error to the listener
+	var listened []error
+	d.Download(func(instrumentCode string, dayHour time.Time, err error, curr, count int) {
+		assert.Equal(t, "EURUSD", instrumentCode)
+		assert.Equal(t, beforeStart.Start, dayHour)
+		listened = append(listened, err)
+	})
+	if assert.Len(t, listened, 1) {
+		assert.ErrorAs(t, listened[0], &beforeStart)
+	}
+}
@@ -0,0 +1,24 @@
+package downloader
+
+import (
+	"context"
+	"github.com/edward-yakop/go-duka/internal/bi5"
+)
+
+// VerifyListener is called for each verified bi5 file. err is nil when the file is valid,
+// otherwise quarantinePath is where the corrupted file was moved to.
+type VerifyListener func(filePath string, err error, quarantinePath string)
+
+// VerifyResult summarizes a cache verification
+type VerifyResult struct {
+	Checked     int
+	Quarantined int
+}
+
+// Verify scans the bi5 cache in folder, limited to instrumentCode unless it's blank.
+// Corrupted files are quarantined so that they are downloaded again on next request.
+func Verify(ctx context.Context, folder, instrumentCode string, listener VerifyListener) (VerifyResult, error) {
+	r, err := bi5.VerifyCache(ctx, folder, instrumentCode, bi5.VerifyListener(listener))
+
+	return VerifyResult(r), err
+}
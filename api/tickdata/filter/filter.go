@@ -0,0 +1,290 @@
+// Package filter cleans tick data from duplicates, crossed or zero quotes and single tick price spikes
+package filter
+
+import (
+	"iter"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/edward-yakop/go-duka/api/tickdata"
+)
+
+// CrossedPolicy tells what to do with ticks whose bid is above the ask
+type CrossedPolicy int
+
+const (
+	// CrossedDrop removes crossed ticks
+	CrossedDrop CrossedPolicy = iota
+	// CrossedSwap swaps the bid and the ask of crossed ticks
+	CrossedSwap
+	// CrossedKeep keeps crossed ticks as they are
+	CrossedKeep
+)
+
+const (
+	defaultWindow       = 50
+	defaultSpikeConfirm = 3
+	minSamples          = 10
+	windowResetGap      = time.Hour
+)
+
+// Report counts the ticks removed by each filter
+type Report struct {
+	Total        int
+	Kept         int
+	Duplicates   int
+	Zero         int
+	Crossed      int
+	Swapped      int
+	SpreadSpikes int
+	SigmaSpikes  int
+}
+
+// Removed is the number of ticks dropped
+func (r Report) Removed() int {
+	return r.Total - r.Kept
+}
+
+func (r Report) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("total", r.Total),
+		slog.Int("kept", r.Kept),
+		slog.Int("duplicates", r.Duplicates),
+		slog.Int("zero", r.Zero),
+		slog.Int("crossed", r.Crossed),
+		slog.Int("swapped", r.Swapped),
+		slog.Int("spreadSpikes", r.SpreadSpikes),
+		slog.Int("sigmaSpikes", r.SigmaSpikes),
+	)
+}
+
+type Option func(f *Filter)
+
+// WithSpreadSpike rejects ticks whose mid price is more than multiple × the median spread away
+// from the median mid price of the rolling window. Disabled when multiple is 0.
+func WithSpreadSpike(multiple float64) Option {
+	return func(f *Filter) {
+		f.spreadMultiple = multiple
+	}
+}
+
+// WithSigmaSpike rejects ticks whose mid price is more than sigma standard deviations away
+// from the mean mid price of the rolling window. Disabled when sigma is 0.
+func WithSigmaSpike(sigma float64) Option {
+	return func(f *Filter) {
+		f.sigma = sigma
+	}
+}
+
+// WithWindow sets the number of accepted ticks the spikes are measured against (default 50)
+func WithWindow(size int) Option {
+	return func(f *Filter) {
+		if size < minSamples {
+			size = minSamples
+		}
+		f.windowSize = size
+	}
+}
+
+// WithSpikeConfirm sets how many consecutive spikes are taken as a genuine price move (default 3).
+// The window restarts from the last of them, the previous ones stay dropped.
+func WithSpikeConfirm(count int) Option {
+	return func(f *Filter) {
+		if count < 1 {
+			count = 1
+		}
+		f.spikeConfirm = count
+	}
+}
+
+// WithCrossed sets the crossed quotes policy (default CrossedDrop)
+func WithCrossed(policy CrossedPolicy) Option {
+	return func(f *Filter) {
+		f.crossed = policy
+	}
+}
+
+// Filter drops exact duplicates of the previous tick, zero or negative quotes, crossed quotes and, when enabled,
+// price spikes. Spikes are measured against a rolling window of the accepted ticks, which restarts after an
+// hour without ticks so that weekend gaps aren't taken for spikes.
+// A Filter is stateful, use one per instrument and feed the ticks in chronological order.
+type Filter struct {
+	spreadMultiple float64
+	sigma          float64
+	windowSize     int
+	spikeConfirm   int
+	crossed        CrossedPolicy
+
+	report   Report
+	last     *tickdata.TickData
+	mids     []float64
+	spreads  []float64
+	lastTime int64
+	spikes   int
+}
+
+func New(opts ...Option) *Filter {
+	f := &Filter{
+		windowSize:   defaultWindow,
+		spikeConfirm: defaultSpikeConfirm,
+		crossed:      CrossedDrop,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// Report of the ticks filtered so far
+func (f *Filter) Report() Report {
+	return f.report
+}
+
+// Accept returns the tick to keep, nil if it is dropped. A swapped crossed tick is returned as a copy.
+func (f *Filter) Accept(tick *tickdata.TickData) *tickdata.TickData {
+	f.report.Total++
+
+	if f.last != nil && *f.last == *tick {
+		f.report.Duplicates++
+		return nil
+	}
+	f.last = tick
+
+	if tick.Ask <= 0 || tick.Bid <= 0 {
+		f.report.Zero++
+		return nil
+	}
+
+	if tick.Bid > tick.Ask {
+		switch f.crossed {
+		case CrossedDrop:
+			f.report.Crossed++
+			return nil
+		case CrossedSwap:
+			swapped := *tick
+			swapped.Ask, swapped.Bid = tick.Bid, tick.Ask
+			swapped.VolumeAsk, swapped.VolumeBid = tick.VolumeBid, tick.VolumeAsk
+			tick = &swapped
+			f.report.Swapped++
+		}
+	}
+
+	if tick.Timestamp-f.lastTime > windowResetGap.Milliseconds() {
+		f.resetWindow()
+	}
+	if counter := f.spikeCounter(tick); counter != nil {
+		f.spikes++
+		if f.spikes < f.spikeConfirm {
+			*counter++
+			return nil
+		}
+		// Consecutive spikes, the price moved
+		f.resetWindow()
+	}
+	f.spikes = 0
+	f.push(tick)
+
+	f.report.Kept++
+	return tick
+}
+
+// Ticks returns the accepted ticks
+func (f *Filter) Ticks(ticks []*tickdata.TickData) []*tickdata.TickData {
+	r := make([]*tickdata.TickData, 0, len(ticks))
+	for _, tick := range ticks {
+		if accepted := f.Accept(tick); accepted != nil {
+			r = append(r, accepted)
+		}
+	}
+
+	return r
+}
+
+// All filters a tick source like stream.All(), ticks.All() or tickdata.DayTicks(day), errors are passed through
+func (f *Filter) All(src iter.Seq2[*tickdata.TickData, error]) iter.Seq2[*tickdata.TickData, error] {
+	return func(yield func(*tickdata.TickData, error) bool) {
+		for tick, err := range src {
+			if err != nil || tick == nil {
+				if !yield(tick, err) {
+					return
+				}
+				continue
+			}
+
+			if accepted := f.Accept(tick); accepted != nil && !yield(accepted, nil) {
+				return
+			}
+		}
+	}
+}
+
+// spikeCounter returns the report counter of the spike, nil if the tick isn't a spike.
+// A tick rejected by both checks is counted as a spread spike.
+func (f *Filter) spikeCounter(tick *tickdata.TickData) *int {
+	if len(f.mids) < minSamples {
+		return nil
+	}
+
+	mid := (tick.Ask + tick.Bid) / 2
+	if f.spreadMultiple > 0 {
+		spread := median(f.spreads)
+		if spread > 0 && math.Abs(mid-median(f.mids)) > f.spreadMultiple*spread {
+			return &f.report.SpreadSpikes
+		}
+	}
+
+	if f.sigma > 0 {
+		mean, stdDev := meanStdDev(f.mids)
+		if stdDev > 0 && math.Abs(mid-mean) > f.sigma*stdDev {
+			return &f.report.SigmaSpikes
+		}
+	}
+
+	return nil
+}
+
+func (f *Filter) push(tick *tickdata.TickData) {
+	if n := len(f.mids); n == f.windowSize {
+		copy(f.mids, f.mids[1:])
+		copy(f.spreads, f.spreads[1:])
+		f.mids = f.mids[:n-1]
+		f.spreads = f.spreads[:n-1]
+	}
+	f.mids = append(f.mids, (tick.Ask+tick.Bid)/2)
+	f.spreads = append(f.spreads, math.Abs(tick.Ask-tick.Bid))
+	f.lastTime = tick.Timestamp
+}
+
+func (f *Filter) resetWindow() {
+	f.mids = f.mids[:0]
+	f.spreads = f.spreads[:0]
+}
+
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func meanStdDev(values []float64) (mean float64, stdDev float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	for _, v := range values {
+		stdDev += (v - mean) * (v - mean)
+	}
+
+	return mean, math.Sqrt(stdDev / float64(len(values)))
+}
@@ -0,0 +1,101 @@
+package filter
+
+import (
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var hour = time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+
+// quiet returns ticks a second apart around 1.2250 with a 0.2 pip spread
+func quiet(count int) []*tickdata.TickData {
+	ticks := make([]*tickdata.TickData, 0, count)
+	for i := 0; i < count; i++ {
+		bid := 1.22500 + float64(i%3)*0.00001
+		ticks = append(ticks, bi5test.Tick("EURUSD", hour.Add(time.Duration(i)*time.Second), bid+0.00002, bid))
+	}
+
+	return ticks
+}
+
+func TestFilter_DuplicatesZeroAndCrossed(t *testing.T) {
+	tick := bi5test.Tick("EURUSD", hour, 1.22502, 1.22500)
+	duplicate := *tick
+	crossed := bi5test.Tick("EURUSD", hour.Add(time.Second), 1.22500, 1.22503)
+	zero := bi5test.Tick("EURUSD", hour.Add(2*time.Second), 0, 1.22500)
+
+	f := New()
+	kept := f.Ticks([]*tickdata.TickData{tick, &duplicate, crossed, zero})
+	assert.Equal(t, []*tickdata.TickData{tick}, kept)
+	assert.Equal(t, Report{Total: 4, Kept: 1, Duplicates: 1, Zero: 1, Crossed: 1}, f.Report())
+	assert.Equal(t, 3, f.Report().Removed())
+
+	f = New(WithCrossed(CrossedSwap))
+	swapped := f.Accept(crossed)
+	if assert.NotNil(t, swapped) {
+		assert.Equal(t, 1.22503, swapped.Ask)
+		assert.Equal(t, 1.22500, swapped.Bid)
+		assert.Equal(t, 1.22500, crossed.Ask, "source tick is left untouched")
+	}
+	assert.Equal(t, 1, f.Report().Swapped)
+}
+
+func TestFilter_SpreadSpike(t *testing.T) {
+	ticks := quiet(20)
+	spike := bi5test.Tick("EURUSD", hour.Add(20*time.Second), 1.23002, 1.23000)
+	after := bi5test.Tick("EURUSD", hour.Add(21*time.Second), 1.22502, 1.22500)
+
+	f := New(WithSpreadSpike(20))
+	kept := f.Ticks(append(ticks, spike, after))
+	assert.Len(t, kept, 21)
+	assert.NotContains(t, kept, spike)
+	assert.Equal(t, 1, f.Report().SpreadSpikes)
+	assert.Equal(t, 1, f.Report().Removed())
+}
+
+func TestFilter_SigmaSpike(t *testing.T) {
+	f := New(WithSigmaSpike(5))
+	f.Ticks(quiet(20))
+	assert.Nil(t, f.Accept(bi5test.Tick("EURUSD", hour.Add(20*time.Second), 1.22552, 1.22550)))
+	assert.Equal(t, 1, f.Report().SigmaSpikes)
+}
+
+func TestFilter_ConfirmedMoveAndGap(t *testing.T) {
+	f := New(WithSpreadSpike(20), WithSpikeConfirm(2))
+	f.Ticks(quiet(20))
+
+	// Two consecutive ticks at the new level, the second one is kept
+	moved := hour.Add(20 * time.Second)
+	assert.Nil(t, f.Accept(bi5test.Tick("EURUSD", moved, 1.23002, 1.23000)))
+	assert.NotNil(t, f.Accept(bi5test.Tick("EURUSD", moved.Add(time.Second), 1.23003, 1.23001)))
+
+	// The window restarts after a gap, like the weekend open
+	f = New(WithSpreadSpike(20))
+	f.Ticks(quiet(20))
+	assert.NotNil(t, f.Accept(bi5test.Tick("EURUSD", hour.Add(48*time.Hour), 1.23002, 1.23000)))
+	assert.Equal(t, 0, f.Report().Removed())
+}
+
+func TestFilter_All(t *testing.T) {
+	tick := bi5test.Tick("EURUSD", hour, 1.22502, 1.22500)
+	failure := errors.New("decode failed")
+	src := func(yield func(*tickdata.TickData, error) bool) {
+		_ = yield(tick, nil) && yield(tick, nil) && yield(nil, failure)
+	}
+
+	var ticks []*tickdata.TickData
+	var errs []error
+	for tick, err := range New().All(src) {
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			ticks = append(ticks, tick)
+		}
+	}
+	assert.Len(t, ticks, 1)
+	assert.Equal(t, []error{failure}, errs)
+}
@@ -0,0 +1,252 @@
+// Package merge streams the ticks of several instruments in a single timestamp ordered sequence
+package merge
+
+import (
+	"container/heap"
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/internal/bi5"
+	"github.com/edward-yakop/go-duka/internal/misc"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// Iterator receives the ticks of all instruments in timestamp order, time is in the location of start.
+// An instrument error is reported with a nil tick, the other instruments keep streaming.
+type Iterator func(instrumentCode string, time time.Time, tick *tickdata.TickData, err error) bool
+
+const defaultPrefetch = 2
+
+type Merge struct {
+	instruments        []*instrument.Metadata
+	start              time.Time
+	end                time.Time
+	downloadFolderPath string
+	opts               []datafeed.Option
+	prefetch           int
+}
+
+// New merges the ticks of the instruments between start and end (both inclusive).
+// Ticks with the same timestamp are ordered like the instruments.
+func New(instruments []*instrument.Metadata, start time.Time, end time.Time, downloadFolderPath string, opts ...datafeed.Option) *Merge {
+	return &Merge{
+		instruments:        instruments,
+		start:              start,
+		end:                end,
+		downloadFolderPath: downloadFolderPath,
+		opts:               opts,
+		prefetch:           defaultPrefetch,
+	}
+}
+
+// SetPrefetch sets how many hours of each instrument are downloaded ahead of the iterator (default 2)
+func (m *Merge) SetPrefetch(hours int) *Merge {
+	if hours < 1 {
+		hours = 1
+	}
+	m.prefetch = hours
+
+	return m
+}
+
+func (m Merge) Start() time.Time {
+	return m.start
+}
+
+func (m Merge) End() time.Time {
+	return m.end
+}
+
+func (m Merge) EachTick(it Iterator) {
+	_ = m.EachTickContext(context.Background(), it)
+}
+
+// EachTickContext streams the merged ticks until the iterator returns false, all instruments are exhausted
+// or ctx is done. Returns ctx.Err() if the merge was interrupted by ctx.
+func (m Merge) EachTickContext(ctx context.Context, it Iterator) error {
+	mergeCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		// Stop the prefetching and wait for the in-flight downloads
+		cancel()
+		wg.Wait()
+	}()
+
+	cursors := make(cursorHeap, 0, len(m.instruments))
+	for i, metadata := range m.instruments {
+		hours := make(chan hourBatch, m.prefetch)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.produce(mergeCtx, metadata, hours)
+		}()
+		cursors = append(cursors, &cursor{order: i, instrumentCode: metadata.Code(), hours: hours})
+	}
+
+	// Wait for the first hour of each instrument
+	active := make(cursorHeap, 0, len(cursors))
+	for _, c := range cursors {
+		if c.next(ctx) {
+			active = append(active, c)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	heap.Init(&active)
+
+	loc := m.start.Location()
+	for active.Len() > 0 {
+		c := active[0]
+		var isContinue bool
+		if c.batch.err != nil {
+			isContinue = it(c.instrumentCode, c.batch.dayHour.In(loc), nil, c.batch.err)
+			c.pos = len(c.batch.ticks)
+		} else {
+			tick := c.batch.ticks[c.pos]
+			isContinue = it(c.instrumentCode, tick.TimeInLocation(loc), tick, nil)
+			c.pos++
+		}
+		if !isContinue {
+			return nil
+		}
+
+		if c.pos < len(c.batch.ticks) || c.next(ctx) {
+			heap.Fix(&active, 0)
+		} else {
+			heap.Pop(&active)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// hourBatch is the tick data of an hour within the merge range, or its error
+type hourBatch struct {
+	dayHour time.Time
+	ticks   []*tickdata.TickData
+	err     error
+}
+
+// produce downloads the instrument hours in chronological order until the range end, ctx is done
+// or an error that can't be recovered from the next hour
+func (m Merge) produce(ctx context.Context, metadata *instrument.Metadata, out chan<- hourBatch) {
+	defer close(out)
+
+	send := func(batch hourBatch) bool {
+		select {
+		case out <- batch:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	start, end, err := datafeed.NewConfig(m.opts...).ClampRange(metadata, m.start, m.end)
+	if err != nil {
+		send(hourBatch{dayHour: misc.ToHourUTC(m.start), err: err})
+		return
+	}
+	start = start.UTC()
+	end = end.UTC()
+
+	for dayHour := misc.ToHourUTC(start); !dayHour.After(end); dayHour = dayHour.Add(time.Hour) {
+		bi := bi5.New(dayHour, metadata, m.downloadFolderPath, m.opts...)
+		err = bi.DownloadContext(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		batch := hourBatch{dayHour: dayHour, err: err}
+		if err == nil {
+			var ticks []*tickdata.TickData
+			ticks, batch.err = bi.Ticks()
+			for _, tick := range ticks {
+				t := tick.UTC()
+				if !(t.Before(start) || t.After(end)) {
+					batch.ticks = append(batch.ticks, tick)
+				}
+			}
+		}
+
+		if (batch.err != nil || len(batch.ticks) > 0) && !send(batch) {
+			return
+		}
+
+		var notCached *datafeed.ErrNotCached
+		if errors.As(err, &notCached) {
+			return
+		}
+	}
+}
+
+// cursor is the position of the iterator in an instrument hour
+type cursor struct {
+	order          int
+	instrumentCode string
+	hours          <-chan hourBatch
+	batch          hourBatch
+	pos            int
+}
+
+// next waits for the next hour, returns false once the instrument is exhausted or ctx is done
+func (c *cursor) next(ctx context.Context) bool {
+	select {
+	case batch, ok := <-c.hours:
+		if !ok {
+			return false
+		}
+		c.batch = batch
+		c.pos = 0
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// timestamp of the next event in milliseconds, errors are reported at the start of their hour
+func (c *cursor) timestamp() int64 {
+	if c.batch.err != nil {
+		return c.batch.dayHour.UnixMilli()
+	}
+
+	return c.batch.ticks[c.pos].Timestamp
+}
+
+type cursorHeap []*cursor
+
+func (h cursorHeap) Len() int {
+	return len(h)
+}
+
+func (h cursorHeap) Less(i, j int) bool {
+	ti, tj := h[i].timestamp(), h[j].timestamp()
+	if ti != tj {
+		return ti < tj
+	}
+
+	return h[i].order < h[j].order
+}
+
+func (h cursorHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *cursorHeap) Push(x any) {
+	*h = append(*h, x.(*cursor))
+}
+
+func (h *cursorHeap) Pop() any {
+	old := *h
+	n := len(old)
+	c := old[n-1]
+	*h = old[:n-1]
+
+	return c
+}
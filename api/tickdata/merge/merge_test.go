@@ -0,0 +1,111 @@
+package merge
+
+import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func writeHour(t *testing.T, mirror, symbol string, dayHour time.Time, offsets ...time.Duration) {
+	ticks := make([]*tickdata.TickData, 0, len(offsets))
+	for _, offset := range offsets {
+		ticks = append(ticks, bi5test.Tick(symbol, dayHour.Add(offset), 1.2, 1.1))
+	}
+	bi5test.WriteMirror(t, mirror, symbol, dayHour, bi5test.Encode(t, dayHour, 100000, ticks...))
+}
+
+type event struct {
+	code string
+	at   time.Time
+	err  bool
+}
+
+func TestMerge_TimestampOrder(t *testing.T) {
+	mirror := t.TempDir()
+	h := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	writeHour(t, mirror, "EURUSD", h, time.Second, 3*time.Second)
+	writeHour(t, mirror, "EURUSD", h.Add(time.Hour), 2*time.Second)
+	writeHour(t, mirror, "GBPUSD", h, time.Second, 2*time.Second)
+	writeHour(t, mirror, "GBPUSD", h.Add(2*time.Hour), time.Second)
+	writeHour(t, mirror, "EURGBP", h.Add(time.Hour), 2*time.Second)
+
+	instruments := []*instrument.Metadata{
+		instrument.GetMetadata("GBPUSD"),
+		instrument.GetMetadata("EURUSD"),
+		instrument.GetMetadata("EURGBP"),
+	}
+	m := New(instruments, h, h.Add(2*time.Hour+30*time.Minute), t.TempDir(), bi5test.FileSource(t, mirror)).SetPrefetch(1)
+
+	var events []event
+	err := m.EachTickContext(context.Background(), func(instrumentCode string, time time.Time, tick *tickdata.TickData, err error) bool {
+		assert.NoError(t, err)
+		assert.Equal(t, instrumentCode, tick.Symbol)
+		events = append(events, event{code: instrumentCode, at: time})
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []event{
+		{code: "GBPUSD", at: h.Add(time.Second)}, // Same timestamp, instruments order
+		{code: "EURUSD", at: h.Add(time.Second)},
+		{code: "GBPUSD", at: h.Add(2 * time.Second)},
+		{code: "EURUSD", at: h.Add(3 * time.Second)},
+		{code: "EURUSD", at: h.Add(time.Hour + 2*time.Second)},
+		{code: "EURGBP", at: h.Add(time.Hour + 2*time.Second)},
+		{code: "GBPUSD", at: h.Add(2*time.Hour + time.Second)},
+	}, events)
+}
+
+func TestMerge_InstrumentErrorDoesNotStopOthers(t *testing.T) {
+	mirror := t.TempDir()
+	h := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	writeHour(t, mirror, "EURUSD", h, time.Second)
+	writeHour(t, mirror, "EURUSD", h.Add(time.Hour), time.Second)
+	writeHour(t, mirror, "GBPUSD", h.Add(time.Hour), time.Second)
+	// Corrupted GBPUSD hour
+	bi5test.WriteMirror(t, mirror, "GBPUSD", h, []byte("not lzma"))
+
+	instruments := []*instrument.Metadata{instrument.GetMetadata("EURUSD"), instrument.GetMetadata("GBPUSD")}
+	m := New(instruments, h, h.Add(time.Hour+30*time.Minute), t.TempDir(), bi5test.FileSource(t, mirror))
+
+	var events []event
+	m.EachTick(func(instrumentCode string, time time.Time, tick *tickdata.TickData, err error) bool {
+		events = append(events, event{code: instrumentCode, at: time, err: err != nil})
+		return true
+	})
+	assert.Equal(t, []event{
+		{code: "GBPUSD", at: h, err: true},
+		{code: "EURUSD", at: h.Add(time.Second)},
+		{code: "EURUSD", at: h.Add(time.Hour + time.Second)},
+		{code: "GBPUSD", at: h.Add(time.Hour + time.Second)},
+	}, events)
+}
+
+func TestMerge_Stop(t *testing.T) {
+	mirror := t.TempDir()
+	h := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		writeHour(t, mirror, "EURUSD", h.Add(time.Duration(i)*time.Hour), time.Second)
+		writeHour(t, mirror, "GBPUSD", h.Add(time.Duration(i)*time.Hour), time.Second)
+	}
+
+	instruments := []*instrument.Metadata{instrument.GetMetadata("EURUSD"), instrument.GetMetadata("GBPUSD")}
+	m := New(instruments, h, h.Add(5*time.Hour), t.TempDir(), bi5test.FileSource(t, mirror))
+
+	count := 0
+	m.EachTick(func(instrumentCode string, time time.Time, tick *tickdata.TickData, err error) bool {
+		count++
+		return count < 3
+	})
+	assert.Equal(t, 3, count)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, m.EachTickContext(ctx, func(string, time.Time, *tickdata.TickData, error) bool {
+		t.Fail()
+		return true
+	}), context.Canceled)
+}
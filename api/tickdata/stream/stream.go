@@ -1,9 +1,12 @@
 package stream
 
 import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/api/tickdata"
 	"github.com/edward-yakop/go-duka/internal/bi5"
+	"iter"
 	"time"
 )
 
@@ -15,6 +18,8 @@ type Stream struct {
 	start              time.Time
 	end                time.Time
 	downloadFolderPath string
+	opts               []datafeed.Option
+	err                error
 }
 
 func (s Stream) Start() time.Time {
@@ -26,6 +31,18 @@ func (s Stream) End() time.Time {
 }
 
 func (s Stream) EachTick(it Iterator) {
+	_ = s.EachTickContext(context.Background(), it)
+}
+
+// EachTickContext streams the ticks until the iterator returns false or ctx is done.
+// Returns ctx.Err() if the stream was interrupted by ctx.
+// A request rejected by the strict range mode is passed to the iterator and returned.
+func (s Stream) EachTickContext(ctx context.Context, it Iterator) error {
+	if s.err != nil {
+		it(s.start, nil, s.err)
+		return s.err
+	}
+
 	start := s.start
 	loc := start.Location()
 	end := s.end.In(loc)
@@ -33,16 +50,27 @@ func (s Stream) EachTick(it Iterator) {
 	dEnd := downloadEnd(s.end)
 	var isContinue = true
 	for t := downloadStart(start); t.Before(dEnd) && isContinue; t = t.Add(time.Hour) {
-		bi := bi5.New(t, s.instrument, s.downloadFolderPath)
-		err := bi.Download()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		bi := bi5.New(t, s.instrument, s.downloadFolderPath, s.opts...)
+		err := bi.DownloadContext(ctx)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if err != nil && !it(t.In(loc), nil, err) {
-			return
+			return nil
 		}
 
 		bi.EachTick(func(tick *tickdata.TickData, err error) bool {
 			if tick == nil {
 				return true
 			}
+			if ctx.Err() != nil {
+				isContinue = false
+				return false
+			}
 			tickTime := tick.TimeInLocation(loc)
 			if (start.Equal(tickTime) || start.Before(tickTime)) &&
 				(end.Equal(tickTime) || end.After(tickTime)) {
@@ -51,6 +79,30 @@ func (s Stream) EachTick(it Iterator) {
 			return isContinue
 		})
 	}
+
+	return ctx.Err()
+}
+
+// All yields the ticks within the stream boundary, download errors are yielded with a nil tick.
+// Breaking out of the loop stops downloading.
+func (s Stream) All() iter.Seq2[*tickdata.TickData, error] {
+	return s.AllContext(context.Background())
+}
+
+// AllContext is All that stops once ctx is done, yielding ctx.Err() last
+func (s Stream) AllContext(ctx context.Context) iter.Seq2[*tickdata.TickData, error] {
+	return func(yield func(*tickdata.TickData, error) bool) {
+		var lastErr error
+		isStopped := false
+		err := s.EachTickContext(ctx, func(_ time.Time, tick *tickdata.TickData, err error) bool {
+			lastErr = err
+			isStopped = !yield(tick, err)
+			return !isStopped
+		})
+		if err != nil && err != lastErr && !isStopped {
+			yield(nil, err)
+		}
+	}
 }
 
 func downloadStart(start time.Time) time.Time {
@@ -65,12 +117,16 @@ func downloadEnd(end time.Time) time.Time {
 	return dEnd
 }
 
-// time are in UTC
-func New(instrument *instrument.Metadata, start time.Time, end time.Time, downloadFolderPath string) *Stream {
+// time are in UTC. A start before the instrument tick data is clamped, unless datafeed.WithStrictRange is set.
+func New(instrument *instrument.Metadata, start time.Time, end time.Time, downloadFolderPath string, opts ...datafeed.Option) *Stream {
+	start, end, err := datafeed.NewConfig(opts...).ClampRange(instrument, start, end)
+
 	return &Stream{
 		instrument:         instrument,
 		start:              start,
 		end:                end,
 		downloadFolderPath: downloadFolderPath,
+		opts:               opts,
+		err:                err,
 	}
 }
@@ -1,8 +1,12 @@
 package stream
 
 import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/internal/bi5"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"os"
@@ -74,3 +78,72 @@ func TestStream_EachTick_OnlyContinueTwice(t *testing.T) {
 	assert.True(t, isRun)
 	assert.Equal(t, 2, tickCount)
 }
+
+func TestStream_EachTickContext_Cancelled(t *testing.T) {
+	start := time.Date(2017, time.January, 10, 22, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	stream := New(instrument.GetMetadata("GBPJPY"), start, end, createEmptyDir(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	isRun := false
+	err := stream.EachTickContext(ctx, func(time time.Time, tick *tickdata.TickData, err error) bool {
+		isRun = true
+		return true
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, isRun)
+}
+
+func TestStream_RangeBeforeDataStart(t *testing.T) {
+	metadata := instrument.GetMetadata("EURUSD")
+	start := time.Date(1990, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2003, time.May, 4, 22, 0, 0, 0, time.UTC)
+
+	stream := New(metadata, start, end, createEmptyDir(t), datafeed.WithOffline())
+	assert.Equal(t, metadata.StartHourForTicks(), stream.Start())
+	assert.Equal(t, end, stream.End())
+
+	stream = New(metadata, start, end, createEmptyDir(t), datafeed.WithOffline(), datafeed.WithStrictRange())
+	var itErr error
+	err := stream.EachTickContext(context.Background(), func(time time.Time, tick *tickdata.TickData, err error) bool {
+		itErr = err
+		return true
+	})
+	var beforeStart *datafeed.ErrBeforeDataStart
+	assert.ErrorAs(t, err, &beforeStart)
+	assert.Equal(t, err, itErr)
+	assert.Equal(t, metadata.StartHourForTicks(), beforeStart.DataStart)
+}
+
+func TestStream_All(t *testing.T) {
+	mirror := createEmptyDir(t)
+	dayHour := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		h := dayHour.Add(time.Duration(i) * time.Hour)
+		bi5test.WriteMirror(t, mirror, "EURUSD", h, bi5test.Encode(t, h, 100000,
+			bi5test.Tick("EURUSD", h.Add(time.Second), 1.22501, 1.22499),
+		))
+	}
+
+	folder := createEmptyDir(t)
+	stream := New(instrument.GetMetadata("EURUSD"), dayHour, dayHour.Add(3*time.Hour), folder, bi5test.FileSource(t, mirror))
+	count := 0
+	for tick, err := range stream.All() {
+		assert.NoError(t, err)
+		assert.Equal(t, dayHour.Add(time.Second), tick.UTC())
+		count++
+		break
+	}
+	assert.Equal(t, 1, count)
+	// Breaking out of the loop stops downloading
+	assert.NoFileExists(t, bi5.BiFilePathTime(folder, "EURUSD", dayHour.Add(time.Hour)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for tick, err := range stream.AllContext(ctx) {
+		assert.Nil(t, tick)
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+}
@@ -1,11 +1,15 @@
 package ticks
 
 import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/api/tickdata"
 	"github.com/edward-yakop/go-duka/internal/bi5"
 	"github.com/edward-yakop/go-duka/internal/misc"
 	"github.com/pkg/errors"
+	"iter"
+	"sort"
 	"time"
 )
 
@@ -14,6 +18,8 @@ type Ticks struct {
 	start              time.Time
 	end                time.Time
 	downloadFolderPath string
+	opts               []datafeed.Option
+	err                error
 
 	currTick     *tickdata.TickData
 	ticksIdx     int
@@ -39,6 +45,17 @@ func (t *Ticks) IsCompleted() bool {
 }
 
 func (t *Ticks) Next() (isSuccess bool, err error) {
+	return t.NextContext(context.Background())
+}
+
+// NextContext moves to the next tick, returns ctx.Err() if ctx is done before the next tick is loaded
+func (t *Ticks) NextContext(ctx context.Context) (isSuccess bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if t.err != nil {
+		return false, t.err
+	}
 	if t.isCompleted {
 		return
 	}
@@ -62,10 +79,19 @@ func (t *Ticks) Next() (isSuccess bool, err error) {
 		}
 	}
 
-	return t.Goto(t.nextDownloadHour())
+	return t.GotoContext(ctx, t.nextDownloadHour())
 }
 
 func (t *Ticks) Goto(to time.Time) (isSuccess bool, err error) {
+	return t.GotoContext(context.Background(), to)
+}
+
+// GotoContext moves to the last tick at or before the requested time,
+// returns ctx.Err() if ctx is done before the tick is loaded
+func (t *Ticks) GotoContext(ctx context.Context, to time.Time) (isSuccess bool, err error) {
+	if t.err != nil {
+		return false, t.err
+	}
 	if to.Before(t.start) || to.After(t.end) {
 		return false, errors.New("[" + to.String() + "] is after [" + t.end.String() + "]")
 	}
@@ -73,24 +99,18 @@ func (t *Ticks) Goto(to time.Time) (isSuccess bool, err error) {
 	to = to.In(time.UTC) // To ease debugging
 	t.isCompleted = false
 	for currTime := misc.ToHourUTC(to); currTime.Before(t.end); currTime = currTime.Add(time.Hour) {
-		if t.ticksDayHour.Equal(currTime) {
-			return t.resetTicksPointer(to)
-		} else {
-			bi := bi5.New(currTime, t.instrument, t.downloadFolderPath)
-
-			// Download might return errors when there's no tick data during weekend or holiday
-			if bi.Download() == nil {
-				t.ticks, err = bi.Ticks()
-				t.ticksIdx = 0
-				t.ticksDayHour = currTime
-				if err != nil {
-					t.complete()
-					return
-				} else if len(t.ticks) != 0 {
-					t.seek(to)
-					return true, nil
-				}
-			}
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		var hasTicks bool
+		if hasTicks, err = t.loadHour(ctx, currTime); err != nil {
+			t.complete()
+			return
+		}
+		if hasTicks {
+			t.seek(to)
+			return true, nil
 		}
 	}
 
@@ -98,10 +118,127 @@ func (t *Ticks) Goto(to time.Time) (isSuccess bool, err error) {
 	return
 }
 
+func (t *Ticks) Prev() (isSuccess bool, err error) {
+	return t.PrevContext(context.Background())
+}
+
+// PrevContext moves to the previous tick, loading the previous hours and skipping the ones without ticks.
+// Returns false and keeps the current tick once the start is reached. Once completed, moves to the last tick.
+func (t *Ticks) PrevContext(ctx context.Context) (isSuccess bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if t.err != nil {
+		return false, t.err
+	}
+
+	if t.currTick == nil {
+		if !t.isCompleted {
+			return
+		}
+		return t.seekBackward(ctx, t.end, true)
+	}
+
+	if t.ticksIdx > 0 {
+		prevTick := t.ticks[t.ticksIdx-1]
+		if prevTick.UTC().Before(t.start) {
+			return
+		}
+		t.ticksIdx--
+		t.currTick = prevTick
+
+		return true, nil
+	}
+
+	// The ticks of the current hour are exhausted, search the previous hours
+	return t.seekBackward(ctx, t.ticksDayHour.Add(-time.Nanosecond), true)
+}
+
+func (t *Ticks) Peek(n int) (*tickdata.TickData, error) {
+	return t.PeekContext(context.Background(), n)
+}
+
+// PeekContext returns the tick n steps away from the current one without moving, a negative n looks backward.
+// Returns nil if the step crosses the range boundaries.
+func (t *Ticks) PeekContext(ctx context.Context, n int) (*tickdata.TickData, error) {
+	saved := t.cursor()
+	defer t.restore(saved)
+
+	for ; n > 0; n-- {
+		if isSuccess, err := t.NextContext(ctx); err != nil || !isSuccess {
+			return nil, err
+		}
+	}
+	for ; n < 0; n++ {
+		if isSuccess, err := t.PrevContext(ctx); err != nil || !isSuccess {
+			return nil, err
+		}
+	}
+
+	return t.currTick, nil
+}
+
+func (t *Ticks) SeekFirstAfter(after time.Time) (isSuccess bool, err error) {
+	return t.SeekFirstAfterContext(context.Background(), after)
+}
+
+// SeekFirstAfterContext moves to the first tick strictly after the requested time.
+// Returns false and keeps the current tick if there's none until the end.
+func (t *Ticks) SeekFirstAfterContext(ctx context.Context, after time.Time) (isSuccess bool, err error) {
+	if t.err != nil {
+		return false, t.err
+	}
+	if after.Before(t.start) {
+		return t.seekForward(ctx, t.start, true)
+	}
+
+	return t.seekForward(ctx, after.UTC(), false)
+}
+
+func (t *Ticks) SeekLastBefore(before time.Time) (isSuccess bool, err error) {
+	return t.SeekLastBeforeContext(context.Background(), before)
+}
+
+// SeekLastBeforeContext moves to the last tick strictly before the requested time.
+// Returns false and keeps the current tick if there's none since the start.
+func (t *Ticks) SeekLastBeforeContext(ctx context.Context, before time.Time) (isSuccess bool, err error) {
+	if t.err != nil {
+		return false, t.err
+	}
+	if before.After(t.end) {
+		return t.seekBackward(ctx, t.end, true)
+	}
+
+	return t.seekBackward(ctx, before.UTC(), false)
+}
+
+// All yields the ticks following the current one until the end, an error is yielded last with a nil tick.
+// Breaking out of the loop keeps the current tick, a later Next resumes after it.
+func (t *Ticks) All() iter.Seq2[*tickdata.TickData, error] {
+	return t.AllContext(context.Background())
+}
+
+// AllContext is All that stops once ctx is done, yielding ctx.Err() last
+func (t *Ticks) AllContext(ctx context.Context) iter.Seq2[*tickdata.TickData, error] {
+	return func(yield func(*tickdata.TickData, error) bool) {
+		for {
+			isSuccess, err := t.NextContext(ctx)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !isSuccess || !yield(t.currTick, nil) {
+				return
+			}
+		}
+	}
+}
+
 func (t *Ticks) complete() {
 	t.isCompleted = true
 	t.ticksIdx = -1
 	t.ticks = nil
+	t.ticksDayHour = time.Time{}
 	t.currTick = nil
 }
 
@@ -116,15 +253,11 @@ func (t *Ticks) nextDownloadHour() time.Time {
 	return misc.ToHourUTC(next)
 }
 
+// seek moves to the last tick of the loaded hour at or before target, or to its first tick
 func (t *Ticks) seek(target time.Time) {
-	count := len(t.ticks)
-	i := t.ticksIdx
-	for ; i < count; i++ {
-		tickTime := t.ticks[i].UTC()
-		if tickTime.After(target) {
-			break
-		}
-	}
+	i := sort.Search(len(t.ticks), func(i int) bool {
+		return t.ticks[i].UTC().After(target)
+	})
 
 	if i > 0 {
 		i--
@@ -133,66 +266,157 @@ func (t *Ticks) seek(target time.Time) {
 	t.currTick = t.ticks[i]
 }
 
-func (t *Ticks) resetTicksPointer(to time.Time) (bool, error) {
-	if t.currTick == nil { // If beginning of hour
-		return t.Next()
-	}
+// seekForward moves to the first tick after from (or at from if inclusive) until the end
+func (t *Ticks) seekForward(ctx context.Context, from time.Time, inclusive bool) (bool, error) {
+	saved := t.cursor()
+	for dayHour := misc.ToHourUTC(from); !dayHour.After(t.end); dayHour = dayHour.Add(time.Hour) {
+		hasTicks, err := t.loadHour(ctx, dayHour)
+		if err != nil {
+			t.restore(saved)
+			return false, err
+		}
+		if !hasTicks {
+			continue
+		}
 
-	currTickTime := t.currTick.UTC()
-	if currTickTime.Before(to) {
-		t.seek(to)
+		i := sort.Search(len(t.ticks), func(i int) bool {
+			tickTime := t.ticks[i].UTC()
+			return tickTime.After(from) || (inclusive && tickTime.Equal(from))
+		})
+		if i == len(t.ticks) {
+			continue
+		}
+		if t.ticks[i].UTC().After(t.end) {
+			break
+		}
+
+		t.moveTo(i)
 		return true, nil
 	}
 
-	// CurrentTick before target is handled above,
-	// We only need to search backward
-	prevTick := t.prevTick()
-	for {
-		if currTickTime.Equal(to) {
-			return true, nil
-		} else {
-			if prevTick != nil {
-				prevTrickTime := prevTick.UTC()
-				if prevTrickTime.Before(to) {
-					return true, nil
-				} else {
-					// Prev is Equal or before, either way, we need to go left
-					t.ticksIdx--
-					t.currTick = prevTick
-
-					if prevTrickTime.Equal(to) {
-						// If it's Equal, we're done
-						return true, nil
-					}
-				}
-			} else {
-				t.ticksIdx = 0
-				t.currTick = t.ticks[0]
-				return true, nil
-			}
+	t.restore(saved)
+	return false, nil
+}
+
+// seekBackward moves to the last tick before from (or at from if inclusive) since the start
+func (t *Ticks) seekBackward(ctx context.Context, from time.Time, inclusive bool) (bool, error) {
+	saved := t.cursor()
+	first := misc.ToHourUTC(t.start)
+	for dayHour := misc.ToHourUTC(from); !dayHour.Before(first); dayHour = dayHour.Add(-time.Hour) {
+		hasTicks, err := t.loadHour(ctx, dayHour)
+		if err != nil {
+			t.restore(saved)
+			return false, err
+		}
+		if !hasTicks {
+			continue
 		}
+
+		i := sort.Search(len(t.ticks), func(i int) bool {
+			tickTime := t.ticks[i].UTC()
+			return tickTime.After(from) || (!inclusive && tickTime.Equal(from))
+		}) - 1
+		if i < 0 {
+			continue
+		}
+		if t.ticks[i].UTC().Before(t.start) {
+			break
+		}
+
+		t.moveTo(i)
+		return true, nil
 	}
+
+	t.restore(saved)
+	return false, nil
 }
 
-func (t *Ticks) prevTick() *tickdata.TickData {
-	if t.ticksIdx == 0 {
-		return nil
+// loadHour loads the ticks of the hour unless already loaded, returns whether the hour has ticks
+func (t *Ticks) loadHour(ctx context.Context, dayHour time.Time) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if !t.ticksDayHour.IsZero() && t.ticksDayHour.Equal(dayHour) {
+		return len(t.ticks) != 0, nil
+	}
+
+	bi := bi5.New(dayHour, t.instrument, t.downloadFolderPath, t.opts...)
+
+	// Download might return errors when there's no tick data during weekend or holiday
+	derr := bi.DownloadContext(ctx)
+	var notCached *datafeed.ErrNotCached
+	if errors.As(derr, &notCached) {
+		return false, derr
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if derr != nil {
+		return false, nil
+	}
+
+	ticks, err := bi.Ticks()
+	if err != nil {
+		return false, err
+	}
+	t.ticks = ticks
+	t.ticksIdx = 0
+	t.ticksDayHour = dayHour
+	t.currTick = nil
+
+	return len(ticks) != 0, nil
+}
+
+func (t *Ticks) moveTo(i int) {
+	t.ticksIdx = i
+	t.currTick = t.ticks[i]
+	t.isCompleted = false
+}
+
+// cursorState is the position of the cursor, saved to look around without moving
+type cursorState struct {
+	currTick     *tickdata.TickData
+	ticksIdx     int
+	ticks        []*tickdata.TickData
+	ticksDayHour time.Time
+	isCompleted  bool
+}
+
+func (t *Ticks) cursor() cursorState {
+	return cursorState{
+		currTick:     t.currTick,
+		ticksIdx:     t.ticksIdx,
+		ticks:        t.ticks,
+		ticksDayHour: t.ticksDayHour,
+		isCompleted:  t.isCompleted,
 	}
-	return t.ticks[t.ticksIdx-1]
+}
+
+func (t *Ticks) restore(state cursorState) {
+	t.currTick = state.currTick
+	t.ticksIdx = state.ticksIdx
+	t.ticks = state.ticks
+	t.ticksDayHour = state.ticksDayHour
+	t.isCompleted = state.isCompleted
 }
 
 var isLogSetup = false
 
-// time are in UTC
-func New(instrument *instrument.Metadata, start time.Time, end time.Time, downloadFolderPath string) *Ticks {
+// time are in UTC. A start before the instrument tick data is clamped, unless datafeed.WithStrictRange is set
+// in which case Next and Goto return *datafeed.ErrBeforeDataStart.
+func New(instrument *instrument.Metadata, start time.Time, end time.Time, downloadFolderPath string, opts ...datafeed.Option) *Ticks {
+	start, end, err := datafeed.NewConfig(opts...).ClampRange(instrument, start, end)
+
 	return &Ticks{
 		instrument:         instrument,
 		start:              start,
 		end:                end,
 		downloadFolderPath: downloadFolderPath,
+		opts:               opts,
+		err:                err,
 
 		ticksDayHour: time.Time{},
 		ticksIdx:     -1,
-		isCompleted:  false,
+		isCompleted:  start.After(end),
 	}
 }
@@ -1,7 +1,10 @@
 package ticks
 
 import (
+	"github.com/edward-yakop/go-duka/api/datafeed"
 	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"os"
@@ -69,3 +72,129 @@ func assertTime(t *testing.T, time time.Time, start time.Time, end time.Time) {
 		t.FailNow()
 	}
 }
+
+func TestTicks_RangeBeforeDataStart(t *testing.T) {
+	metadata := instrument.GetMetadata("EURUSD")
+	start := time.Date(1990, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	ticks := New(metadata, start, end, createEmptyDir(t), datafeed.WithOffline())
+	assert.True(t, ticks.IsCompleted())
+	isSuccess, err := ticks.Next()
+	assert.False(t, isSuccess)
+	assert.NoError(t, err)
+
+	ticks = New(metadata, start, end, createEmptyDir(t), datafeed.WithOffline(), datafeed.WithStrictRange())
+	isSuccess, err = ticks.Next()
+	assert.False(t, isSuccess)
+	var beforeStart *datafeed.ErrBeforeDataStart
+	assert.ErrorAs(t, err, &beforeStart)
+}
+
+func TestTicks_All(t *testing.T) {
+	mirror := createEmptyDir(t)
+	dayHour := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		h := dayHour.Add(time.Duration(i) * time.Hour)
+		bi5test.WriteMirror(t, mirror, "EURUSD", h, bi5test.Encode(t, h, 100000,
+			bi5test.Tick("EURUSD", h.Add(time.Second), 1.22501, 1.22499),
+			bi5test.Tick("EURUSD", h.Add(2*time.Second), 1.22502, 1.22498),
+		))
+	}
+
+	ticks := New(instrument.GetMetadata("EURUSD"), dayHour, dayHour.Add(3*time.Hour), createEmptyDir(t), bi5test.FileSource(t, mirror))
+	var times []time.Time
+	for tick, err := range ticks.All() {
+		assert.NoError(t, err)
+		times = append(times, tick.UTC())
+		if len(times) == 3 {
+			break
+		}
+	}
+	assert.Equal(t, dayHour.Add(time.Hour+time.Second), ticks.Current().UTC())
+
+	// Resumes after the current tick
+	for tick, err := range ticks.All() {
+		assert.NoError(t, err)
+		times = append(times, tick.UTC())
+	}
+	assert.Len(t, times, 6)
+	assert.Equal(t, dayHour.Add(2*time.Hour+2*time.Second), times[5])
+	assert.True(t, ticks.IsCompleted())
+}
+
+func TestTicks_PrevPeekAndSeek(t *testing.T) {
+	mirror := createEmptyDir(t)
+	friday := time.Date(2021, time.January, 8, 21, 0, 0, 0, time.UTC)
+	sunday := time.Date(2021, time.January, 10, 22, 0, 0, 0, time.UTC)
+	for _, h := range []time.Time{friday, sunday} {
+		bi5test.WriteMirror(t, mirror, "EURUSD", h, bi5test.Encode(t, h, 100000,
+			bi5test.Tick("EURUSD", h.Add(time.Second), 1.22501, 1.22499),
+			bi5test.Tick("EURUSD", h.Add(2*time.Second), 1.22502, 1.22498),
+			bi5test.Tick("EURUSD", h.Add(3*time.Second), 1.22503, 1.22497),
+		))
+	}
+
+	ticks := New(instrument.GetMetadata("EURUSD"), friday, sunday.Add(time.Hour), createEmptyDir(t), bi5test.FileSource(t, mirror))
+
+	// Nothing before the first tick
+	isSuccess, err := ticks.Prev()
+	assert.NoError(t, err)
+	assert.False(t, isSuccess)
+
+	isSuccess, err = ticks.SeekFirstAfter(sunday.Add(time.Second))
+	assert.NoError(t, err)
+	if assert.True(t, isSuccess) {
+		assert.Equal(t, sunday.Add(2*time.Second), ticks.Current().UTC())
+	}
+
+	// Crosses the weekend backward
+	assert.NoError(t, moved(ticks.Prev()))
+	assert.NoError(t, moved(ticks.Prev()))
+	assert.Equal(t, friday.Add(3*time.Second), ticks.Current().UTC())
+
+	// Peek doesn't move
+	peeked, err := ticks.Peek(2)
+	if assert.NoError(t, err) && assert.NotNil(t, peeked) {
+		assert.Equal(t, sunday.Add(2*time.Second), peeked.UTC())
+	}
+	peeked, err = ticks.Peek(-2)
+	if assert.NoError(t, err) && assert.NotNil(t, peeked) {
+		assert.Equal(t, friday.Add(time.Second), peeked.UTC())
+	}
+	peeked, err = ticks.Peek(-3)
+	assert.NoError(t, err)
+	assert.Nil(t, peeked)
+	assert.Equal(t, friday.Add(3*time.Second), ticks.Current().UTC())
+
+	isSuccess, err = ticks.SeekLastBefore(sunday.Add(time.Second))
+	assert.NoError(t, err)
+	if assert.True(t, isSuccess) {
+		assert.Equal(t, friday.Add(3*time.Second), ticks.Current().UTC())
+	}
+	isSuccess, err = ticks.SeekLastBefore(friday.Add(time.Second))
+	assert.NoError(t, err)
+	assert.False(t, isSuccess)
+	assert.Equal(t, friday.Add(3*time.Second), ticks.Current().UTC())
+
+	// Next resumes after a backward move
+	assert.NoError(t, moved(ticks.Next()))
+	assert.Equal(t, sunday.Add(time.Second), ticks.Current().UTC())
+
+	// Once completed, Prev moves to the last tick
+	for tick, err := range ticks.All() {
+		assert.NoError(t, err)
+		assert.NotNil(t, tick)
+	}
+	assert.True(t, ticks.IsCompleted())
+	assert.NoError(t, moved(ticks.Prev()))
+	assert.Equal(t, sunday.Add(3*time.Second), ticks.Current().UTC())
+}
+
+func moved(isSuccess bool, err error) error {
+	if err == nil && !isSuccess {
+		return errors.New("cursor didn't move")
+	}
+
+	return err
+}
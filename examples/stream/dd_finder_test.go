@@ -2,9 +2,9 @@ package stream
 
 import (
 	"fmt"
+	"github.com/edward-yakop/go-duka/api/analysis"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/api/tickdata"
-	"github.com/edward-yakop/go-duka/api/tickdata/stream"
 	"github.com/edward-yakop/go-duka/internal/misc"
 	"github.com/stretchr/testify/assert"
 	"log/slog"
@@ -25,15 +25,33 @@ func Test_StreamExample_DDFinder(t *testing.T) {
 	closeTime := time.Date(2020, time.November, 4, 00, 56, 56, 0, loc)
 	closePrice := 136.725
 
-	openPriceDiff, maxDD, maxPositive, maxDDForMaxPositive, maxPositiveTime, closePriceDiff :=
-		buyDDFinder(t, im, openTime, closeTime, openPrice, closePrice)
+	e, err := analysis.Analyze(im, analysis.Trade{
+		Side:       analysis.Buy,
+		OpenTime:   openTime,
+		OpenPrice:  openPrice,
+		CloseTime:  closeTime,
+		ClosePrice: closePrice,
+	}, ".")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	logTick(t, "open", e.EntryTick.TimeInLocation(loc), e.EntryTick)
+	logTick(t, "close", e.ExitTick.TimeInLocation(loc), e.ExitTick)
+
+	// The example measured the open price against the ask and the close price against the bid
+	openPriceDiff := analysis.Points(im, e.EntrySlippage)
+	maxDD := analysis.Points(im, e.MAE)
+	maxPositive := analysis.Points(im, e.MFE)
+	maxDDForMaxPositive := analysis.Points(im, e.DrawdownBeforeMFE)
+	closePriceDiff := analysis.Points(im, e.ExitSlippage)
 
 	t.Log("Open price diff in [", strconv.Itoa(openPriceDiff), "] points")
 	t.Log("Max DD [", strconv.Itoa(maxDD), "] points")
 	t.Log("Max Positive [", strconv.Itoa(maxPositive), "] points")
-	t.Log("Max Positive Time [", fmtTime(maxPositiveTime), "] Duration [", fmtDuration(maxPositiveTime.Sub(openTime)), "]")
+	t.Log("Max Positive Time [", fmtTime(e.MFETime), "] Duration [", fmtDuration(e.TimeToMFE), "]")
 	t.Log("Max DD for Max Positive [", strconv.Itoa(maxDDForMaxPositive), "] points")
 	t.Log("Close price diff in [", strconv.Itoa(closePriceDiff), "] points")
+	t.Log("Spread cost [", strconv.Itoa(analysis.Points(im, e.SpreadCost)), "] points")
 	profitInPoints := int(math.Round((closePrice - openPrice) * 1000))
 	t.Log("Profit [", strconv.Itoa(profitInPoints), "] points Duration [", fmtDuration(closeTime.Sub(openTime)), "]")
 
@@ -45,48 +63,6 @@ func Test_StreamExample_DDFinder(t *testing.T) {
 	assert.Equal(t, 400, profitInPoints, "profitInPoints")
 }
 
-func buyDDFinder(t *testing.T, instrument *instrument.Metadata, openTime time.Time, closeTime time.Time, openPrice float64, closePrice float64) (
-	openPriceDiff int, maxDD int, maxPositive int, maxDDForMaxPositive int, maxPositiveTime time.Time, closePriceDiff int,
-) {
-	start := openTime.Add(-1 * time.Minute)
-	end := closeTime.Add(time.Minute)
-	s := stream.New(instrument, start, end, ".")
-
-	maxDD = math.MaxInt32
-	openPriceDiff = math.MaxInt32
-	closePriceDiff = math.MaxInt32
-	maxPositive = math.MinInt32
-	s.EachTick(func(tickTime time.Time, tick *tickdata.TickData, err error) bool {
-		if openTime.Sub(tickTime) > 0 {
-			return true
-		}
-
-		if openPriceDiff == math.MaxInt32 {
-			logTick(t, "open", tickTime, tick)
-			openPriceDiff = int(math.Round((openPrice - tick.Ask) * 1000))
-		}
-
-		dd := math.Round((tick.Bid - openPrice) * 1000)
-		maxDD = int(math.Min(float64(maxDD), dd))
-
-		ddInInt := int(dd)
-		if maxPositive <= ddInInt {
-			maxPositive = ddInInt
-			maxPositiveTime = tickTime
-			maxDDForMaxPositive = maxDD
-		}
-
-		if closeTime.Sub(tickTime) <= 0 && closePriceDiff == math.MaxInt32 {
-			logTick(t, "close", tickTime, tick)
-			closePriceDiff = int(math.Round((tick.Bid - closePrice) * 1000))
-			return false
-		}
-
-		return true
-	})
-	return openPriceDiff, maxDD, maxPositive, maxDDForMaxPositive, maxPositiveTime, closePriceDiff
-}
-
 func logTick(t *testing.T, op string, tickTime time.Time, tick *tickdata.TickData) {
 	if tick == nil {
 		return
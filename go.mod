@@ -1,6 +1,6 @@
 module github.com/edward-yakop/go-duka
 
-go 1.22
+go 1.23
 
 require (
 	github.com/go-resty/resty/v2 v2.14.0
@@ -1,9 +1,13 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"github.com/edward-yakop/go-duka/api/bars"
+	"github.com/edward-yakop/go-duka/api/datafeed"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/api/tickdata/filter"
 	iTickdata "github.com/edward-yakop/go-duka/internal/tickdata"
 	"github.com/pkg/errors"
 	"log/slog"
@@ -27,21 +31,51 @@ var (
 type ArgsList struct {
 	Verbose bool
 	Header  bool
+	Verify  bool
+	Offline bool
+	Strict  bool
 	Spread  uint
 	Model   uint
 	Dump    string
 	Symbol  string
+	Source  string
+	Markers string
 	Output  string
 	Format  string
 	Period  string
 	Start   string
 	End     string
+
+	RevalidateRecent   time.Duration
+	RevalidateSettle   time.Duration
+	RefreshInstruments bool
+	Synthetic          string
+
+	WeekStart  string
+	Timezone   string
+	ServerTime string
+
+	Clean       bool
+	CleanSpread float64
+	CleanSigma  float64
+
+	Journal       string
+	JournalReport string
+	BrokerOffset  string
+
+	List      bool
+	Json      bool
+	Query     string
+	Group     string
+	Currency  string
+	Available string
 }
 
 // DukaApp used to download source tick data
 type DukaApp struct {
 	option  AppOption
 	outputs []core.Converter
+	filter  *filter.Filter
 }
 
 // AppOption download options
@@ -51,7 +85,15 @@ type AppOption struct {
 	Instrument *instrument.Metadata
 	Format     string
 	Folder     string
+	Source     string
+	Revalidate datafeed.RevalidatePolicy
+	Offline    bool
+	Strict     bool
 	Periods    string
+	WeekStart  time.Weekday
+	Alignment  bars.Alignment
+	ServerTime bars.Alignment
+	Clean      []filter.Option
 	Spread     uint32
 	Mode       uint32
 	CsvHeader  bool
@@ -70,7 +112,7 @@ func ParseOption(args ArgsList) (*AppOption, error) {
 	}
 
 	if metadata == nil {
-		err = fmt.Errorf("invalid symbol parameter [%s]", args.Symbol)
+		err = invalidSymbol(args, args.Symbol)
 		return nil, err
 	}
 	// check format
@@ -88,9 +130,21 @@ func ParseOption(args ArgsList) (*AppOption, error) {
 		}
 		opt.Format = format
 	}
+	if opt.Source, err = parseSourceArgument(args.Source); err != nil {
+		return nil, err
+	}
+	opt.Revalidate = parseRevalidateArguments(args)
+	opt.Offline = args.Offline
+	opt.Strict = args.Strict
+	if args.Clean {
+		opt.Clean = []filter.Option{filter.WithSpreadSpike(args.CleanSpread), filter.WithSigmaSpike(args.CleanSigma)}
+	}
 	if err = handleTimeArguments(args, &opt); err != nil {
 		return nil, err
 	}
+	if err = clampTimeArguments(&opt); err != nil {
+		return nil, err
+	}
 	if opt.Folder, err = filepath.Abs(args.Output); err != nil {
 		err = fmt.Errorf("invalid destination folder")
 		return nil, err
@@ -100,6 +154,16 @@ func ParseOption(args ArgsList) (*AppOption, error) {
 		return nil, err
 	}
 
+	if opt.WeekStart, err = parseWeekStartArgument(args.WeekStart); err != nil {
+		return nil, err
+	}
+	if opt.Alignment, err = bars.ParseAlignment(args.Timezone); err != nil {
+		return nil, errors.Wrap(err, "invalid tz parameter")
+	}
+	if err = parseServerTimeArgument(args.ServerTime, &opt); err != nil {
+		return nil, err
+	}
+
 	if args.Period != "" {
 		args.Period = strings.ToUpper(args.Period)
 		if !core.TimeframeRegx.MatchString(args.Period) {
@@ -128,6 +192,88 @@ func handleTimeArguments(args ArgsList, opt *AppOption) (err error) {
 	return
 }
 
+// clampTimeArguments moves the start to the first day with tick data, or fails in strict mode
+func clampTimeArguments(opt *AppOption) error {
+	start, _, err := datafeed.NewConfig(opt.DatafeedOptions()...).ClampRange(opt.Instrument, opt.Start, opt.End)
+	if err != nil {
+		return err
+	}
+
+	// Days are exported as a whole
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	if !start.Before(opt.End) {
+		return fmt.Errorf("no tick data between start and end, [%s] tick data starts at [%s]",
+			opt.Instrument.Code(), datafeed.DataStart(opt.Instrument).UTC().Format("2006-01-02:15H"))
+	}
+	opt.Start = start
+
+	return nil
+}
+
+// parseServerTimeArgument sets the broker server time, MT4 bars are aligned on the broker wall clock
+func parseServerTimeArgument(serverTime string, opt *AppOption) (err error) {
+	if strings.TrimSpace(serverTime) == "" {
+		return nil
+	}
+	if opt.ServerTime, err = bars.ParseAlignment(serverTime); err != nil {
+		return errors.Wrap(err, "invalid server-time parameter")
+	}
+	if opt.Alignment != (bars.Alignment{}) && opt.Alignment.String() != opt.ServerTime.String() {
+		return fmt.Errorf("server-time [%s] conflicts with tz [%s], bars are aligned on the server time", opt.ServerTime, opt.Alignment)
+	}
+	opt.Alignment = opt.ServerTime
+
+	return nil
+}
+
+func parseWeekStartArgument(weekStart string) (time.Weekday, error) {
+	switch strings.ToLower(weekStart) {
+	case "", "sunday":
+		return time.Sunday, nil
+	case "monday":
+		return time.Monday, nil
+	default:
+		return time.Sunday, fmt.Errorf("invalid week-start parameter [%s], supported sunday/monday", weekStart)
+	}
+}
+
+func parseSourceArgument(source string) (string, error) {
+	if source == "" {
+		return datafeed.DefaultURL, nil
+	}
+
+	return datafeed.ParseSource(source)
+}
+
+// parseRevalidateArguments falls back to datafeed.DefaultRevalidatePolicy when neither duration is set,
+// like an ArgsList built in code. A negative duration disables its revalidation.
+func parseRevalidateArguments(args ArgsList) datafeed.RevalidatePolicy {
+	if args.RevalidateRecent == 0 && args.RevalidateSettle == 0 {
+		return datafeed.DefaultRevalidatePolicy
+	}
+
+	return datafeed.RevalidatePolicy{
+		Recent: args.RevalidateRecent,
+		Settle: args.RevalidateSettle,
+	}
+}
+
+// DatafeedOptions returns the options used to populate the bi5 cache
+func (opt AppOption) DatafeedOptions() []datafeed.Option {
+	opts := []datafeed.Option{
+		datafeed.WithSource(opt.Source),
+		datafeed.WithRevalidatePolicy(opt.Revalidate),
+	}
+	if opt.Offline {
+		opts = append(opts, datafeed.WithOffline())
+	}
+	if opt.Strict {
+		opts = append(opts, datafeed.WithStrictRange())
+	}
+
+	return opts
+}
+
 func parseDateArgument(dateString string) (time.Time, error) {
 	return time.ParseInLocation("2006-01-02", dateString, time.UTC)
 }
@@ -137,17 +283,20 @@ func NewOutputs(opt *AppOption) []core.Converter {
 	outs := make([]core.Converter, 0)
 	for _, period := range strings.Split(opt.Periods, ",") {
 		var format core.Converter
-		timeframe, _ := core.ParseTimeframe(strings.Trim(period, " \t\r\n"))
+		barsTimeframe := core.ParseBarsTimeframe(strings.Trim(period, " \t\r\n")).
+			WithWeekStart(opt.WeekStart).
+			WithAlignment(opt.Alignment)
+		timeframe := barsTimeframe.Minutes()
 
 		switch opt.Format {
 		case "csv":
 			format = csv.New(opt.Start, opt.End, opt.CsvHeader, opt.Instrument, opt.Folder)
 			break
 		case "fxt":
-			format = fxt4.NewFxtFile(timeframe, opt.Spread, opt.Mode, opt.Folder, opt.Instrument)
+			format = fxt4.NewFxtFile(timeframe, opt.Spread, opt.Mode, opt.Folder, opt.Instrument, opt.ServerTime)
 			break
 		case "hst":
-			format = hst.NewHST(timeframe, opt.Spread, opt.Instrument, opt.Folder)
+			format = hst.NewHST(timeframe, opt.Spread, opt.Instrument, opt.Folder, opt.ServerTime)
 			break
 		default:
 			slog.Error("unsupported format", slog.String("format", opt.Format))
@@ -155,21 +304,32 @@ func NewOutputs(opt *AppOption) []core.Converter {
 			return nil
 		}
 
-		outs = append(outs, core.NewTimeframe(period, opt.Instrument, format))
+		outs = append(outs, core.NewBarsTimeframe(barsTimeframe, opt.Instrument, format))
 	}
 	return outs
 }
 
 // NewApp create an application instance by input arguments
 func NewApp(opt *AppOption) *DukaApp {
-	return &DukaApp{
+	app := &DukaApp{
 		option:  *opt,
 		outputs: NewOutputs(opt),
 	}
+	if opt.Clean != nil {
+		app.filter = filter.New(opt.Clean...)
+	}
+
+	return app
 }
 
 // Execute download source bi5 tick data from dukascopy
 func (app *DukaApp) Execute() error {
+	return app.ExecuteContext(context.Background())
+}
+
+// ExecuteContext download source bi5 tick data from dukascopy until ctx is done.
+// Outputs are flushed with the days exported so far when ctx is done.
+func (app *DukaApp) ExecuteContext(ctx context.Context) error {
 	var (
 		opt       = app.option
 		startTime = time.Now()
@@ -191,13 +351,22 @@ func (app *DukaApp) Execute() error {
 	}
 
 	// Download by day, 24 hours a day data is downloaded in parallel by 24 goroutines
+	var execErr error
 	for day := opt.Start; day.Unix() < opt.End.Unix(); day = day.Add(24 * time.Hour) {
 		// Download, parse, store
-		if td, err := iTickdata.FetchDay(opt.Instrument, day, opt.Folder); err != nil {
-			err = errors.Wrap(err, "Failed to fetch ["+misc.TimeToDayString(day)+"]")
-			return err
+		if td, err := iTickdata.FetchDayContext(ctx, opt.Instrument, day, opt.Folder, opt.DatafeedOptions()...); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				slog.Warn("Interrupted, flushing exported days", slog.String("day", misc.TimeToDayString(day)))
+				execErr = ctxErr
+			} else {
+				execErr = errors.Wrap(err, "Failed to fetch ["+misc.TimeToDayString(day)+"]")
+			}
+			break
 		} else if err = app.export(td); err != nil {
-			err = errors.Wrap(err, "Failed to export ["+misc.TimeToDayString(day)+"]")
+			slog.Error("Failed to export",
+				slog.String("day", misc.TimeToDayString(day)),
+				slog.Any("error", err),
+			)
 		}
 	}
 
@@ -212,9 +381,12 @@ func (app *DukaApp) Execute() error {
 	}
 
 	wg.Wait()
+	if app.filter != nil {
+		slog.Info("Cleaned ticks", slog.Any("report", app.filter.Report()))
+	}
 	slog.Info("Time cost", slog.Duration("duration", time.Since(startTime)))
 
-	return nil
+	return execErr
 }
 
 // export
@@ -234,6 +406,10 @@ func (app *DukaApp) export(td tickdata.Day) error {
 		return true
 	})
 
+	if app.filter != nil {
+		dayTicks = app.filter.Ticks(dayTicks)
+	}
+
 	timestamp := uint32(day.Unix())
 	for _, out := range app.outputs {
 		err := out.PackTicks(timestamp, dayTicks[:])
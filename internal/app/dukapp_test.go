@@ -2,8 +2,15 @@ package app
 
 import (
 	"fmt"
+	"github.com/edward-yakop/go-duka/api/datafeed"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
+	"github.com/stretchr/testify/assert"
+	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestDukaApp(t *testing.T) {
@@ -42,3 +49,162 @@ func TestDukaApp(t *testing.T) {
 	app := NewApp(opt)
 	_ = app.Execute()
 }
+
+func TestParseOption_StartBeforeDataStart(t *testing.T) {
+	args := ArgsList{
+		Symbol: "EURUSD",
+		Format: "csv",
+		Output: t.TempDir(),
+		Start:  "1990-01-01",
+		End:    "2003-05-06",
+	}
+
+	opt, err := ParseOption(args)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2003, time.May, 4, 0, 0, 0, 0, time.UTC), opt.Start)
+	}
+
+	args.End = "2003-05-01"
+	_, err = ParseOption(args)
+	assert.Error(t, err)
+
+	args.End = "2003-05-06"
+	args.Strict = true
+	_, err = ParseOption(args)
+	var beforeStart *datafeed.ErrBeforeDataStart
+	assert.ErrorAs(t, err, &beforeStart)
+}
+
+func TestParseOption_Timezone(t *testing.T) {
+	args := ArgsList{
+		Symbol:   "EURUSD",
+		Format:   "hst",
+		Output:   t.TempDir(),
+		Start:    "2021-01-04",
+		End:      "2021-01-05",
+		Timezone: "ny-close",
+	}
+
+	opt, err := ParseOption(args)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 7*time.Hour, opt.Alignment.Shift)
+	}
+
+	args.Timezone = "Mars/Olympus"
+	_, err = ParseOption(args)
+	assert.Error(t, err)
+}
+
+func TestParseOption_ServerTime(t *testing.T) {
+	args := ArgsList{
+		Symbol:     "EURUSD",
+		Format:     "fxt",
+		Output:     t.TempDir(),
+		Start:      "2021-01-04",
+		End:        "2021-01-05",
+		ServerTime: "+02:00",
+	}
+
+	opt, err := ParseOption(args)
+	if assert.NoError(t, err) {
+		at := time.Date(2021, time.January, 4, 22, 0, 0, 0, time.UTC)
+		assert.Equal(t, time.Date(2021, time.January, 5, 0, 0, 0, 0, time.UTC), opt.ServerTime.WallClock(at))
+		assert.Equal(t, opt.ServerTime, opt.Alignment)
+	}
+
+	args.Timezone = "Europe/Athens"
+	_, err = ParseOption(args)
+	assert.Error(t, err)
+
+	args.Timezone = "UTC"
+	args.ServerTime = "Mars/Olympus"
+	_, err = ParseOption(args)
+	assert.Error(t, err)
+}
+
+func TestNewApp_Clean(t *testing.T) {
+	args := ArgsList{
+		Symbol: "EURUSD",
+		Format: "csv",
+		Output: t.TempDir(),
+		Start:  "2021-01-04",
+		End:    "2021-01-05",
+	}
+
+	opt, err := ParseOption(args)
+	if assert.NoError(t, err) {
+		assert.Nil(t, NewApp(opt).filter)
+	}
+
+	args.Clean = true
+	args.CleanSpread = 50
+	opt, err = ParseOption(args)
+	if assert.NoError(t, err) {
+		assert.NotNil(t, NewApp(opt).filter)
+	}
+}
+
+func TestNewApp_Synthetic(t *testing.T) {
+	dayHour := time.Date(2021, time.January, 4, 10, 0, 0, 0, time.UTC)
+	mirror := t.TempDir()
+	bi5test.WriteMirror(t, mirror, "EURUSD", dayHour, bi5test.Encode(t, dayHour, 100000,
+		bi5test.Tick("EURUSD", dayHour.Add(time.Second), 1.10002, 1.10000),
+	))
+	bi5test.WriteMirror(t, mirror, "USDNOK", dayHour, bi5test.Encode(t, dayHour, 100000,
+		bi5test.Tick("USDNOK", dayHour.Add(2*time.Second), 10.00050, 10.00000),
+	))
+
+	args := ArgsList{
+		Symbol:    "EURNOKS",
+		Synthetic: "EURNOKS=EURUSD*USDNOK, EURGBPS=EURUSD/GBPUSD",
+		Format:    "csv",
+		Output:    t.TempDir(),
+		Source:    (&url.URL{Scheme: "file", Path: filepath.ToSlash(mirror)}).String(),
+		Start:     "2021-01-04",
+		End:       "2021-01-05",
+	}
+	_, err := ParseOption(args)
+	assert.Error(t, err, "not registered yet")
+
+	if !assert.NoError(t, RegisterSynthetics(args)) {
+		t.FailNow()
+	}
+	assert.NotNil(t, instrument.GetMetadata("EURGBPS").Synthetic())
+
+	opt, err := ParseOption(args)
+	if !assert.NoError(t, err) || !assert.NoError(t, NewApp(opt).Execute()) {
+		t.FailNow()
+	}
+
+	files, _ := filepath.Glob(filepath.Join(opt.Folder, "EURNOKS-*"))
+	if assert.Len(t, files, 1) {
+		content, err := os.ReadFile(files[0])
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "11.00076")
+	}
+
+	assert.Error(t, RegisterSynthetics(ArgsList{Synthetic: "EURNOKS=EURUSD+USDNOK"}))
+}
+
+func TestParseOption_Revalidate(t *testing.T) {
+	args := ArgsList{
+		Symbol: "EURUSD",
+		Format: "csv",
+		Output: t.TempDir(),
+		Start:  "2021-01-04",
+		End:    "2021-01-05",
+	}
+
+	// An ArgsList built in code gets the library default
+	opt, err := ParseOption(args)
+	if assert.NoError(t, err) {
+		assert.Equal(t, datafeed.DefaultRevalidatePolicy, opt.Revalidate)
+	}
+
+	args.RevalidateRecent, args.RevalidateSettle = time.Hour, -1
+	opt, err = ParseOption(args)
+	if assert.NoError(t, err) {
+		assert.Equal(t, datafeed.RevalidatePolicy{Recent: time.Hour, Settle: -1}, opt.Revalidate)
+		assert.False(t, opt.Revalidate.IsStale(time.Date(2021, time.January, 4, 0, 0, 0, 0, time.UTC), time.Now(), time.Now()))
+	}
+}
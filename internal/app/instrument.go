@@ -0,0 +1,53 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const instrumentsMaxAge = 24 * time.Hour
+
+// RefreshInstruments replaces the embedded instrument metadata with the latest published one.
+// The retrieved metadata is cached in the output folder for a day.
+func RefreshInstruments(ctx context.Context, args ArgsList) error {
+	folder, err := filepath.Abs(args.Output)
+	if err != nil {
+		return err
+	}
+
+	return instrument.Refresh(ctx, instrument.RefreshOptions{
+		CacheDir: filepath.Join(folder, "instruments"),
+		MaxAge:   instrumentsMaxAge,
+	})
+}
+
+// invalidSymbol error, hinting at -refresh-instruments since the embedded metadata might miss newer instruments
+func invalidSymbol(args ArgsList, symbol string) error {
+	if args.RefreshInstruments {
+		return fmt.Errorf("invalid symbol parameter [%s]", symbol)
+	}
+
+	return fmt.Errorf("invalid symbol parameter [%s], not in the embedded instrument list, try -refresh-instruments", symbol)
+}
+
+// RegisterSynthetics registers the comma separated synthetic instrument definitions, like EURNOK=EURUSD*USDNOK
+func RegisterSynthetics(args ArgsList) error {
+	for _, definition := range strings.Split(args.Synthetic, ",") {
+		if strings.TrimSpace(definition) == "" {
+			continue
+		}
+
+		m, err := instrument.RegisterSynthetic(definition)
+		if err != nil {
+			return err
+		}
+		slog.Info("Registered synthetic instrument", slog.String("code", m.Code()), slog.String("legs", m.Synthetic().String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,334 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/edward-yakop/go-duka/api/analysis"
+	"github.com/edward-yakop/go-duka/api/bars"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/pkg/errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// journalTimeLayouts are the time formats of the MT4/MT5 statements and ISO
+var journalTimeLayouts = []string{
+	"2006.01.02 15:04:05",
+	"2006.01.02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+}
+
+// journalTrade is a trade of the journal, times are broker times converted to UTC
+type journalTrade struct {
+	metadata   *instrument.Metadata
+	trade      analysis.Trade
+	serverTime bars.Alignment
+}
+
+type journalReport struct {
+	Symbol                  string     `json:"symbol"`
+	Side                    string     `json:"side"`
+	OpenTime                time.Time  `json:"openTime"`
+	OpenPrice               float64    `json:"openPrice"`
+	CloseTime               time.Time  `json:"closeTime"`
+	ClosePrice              float64    `json:"closePrice"`
+	Ticks                   int        `json:"ticks"`
+	MAEPoints               int        `json:"maePoints"`
+	MFEPoints               int        `json:"mfePoints"`
+	DrawdownBeforeMFEPoints int        `json:"drawdownBeforeMfePoints"`
+	MFETime                 *time.Time `json:"mfeTime,omitempty"`
+	TimeToMFE               string     `json:"timeToMfe"`
+	EntrySlippagePoints     int        `json:"entrySlippagePoints"`
+	ExitSlippagePoints      int        `json:"exitSlippagePoints"`
+	SpreadCostPoints        int        `json:"spreadCostPoints"`
+	StopLossTime            *time.Time `json:"stopLossTime,omitempty"`
+	TakeProfitTime          *time.Time `json:"takeProfitTime,omitempty"`
+	Error                   string     `json:"error,omitempty"`
+
+	serverTime bars.Alignment
+}
+
+// Journal analyzes the trades of the journal CSV and writes a report per trade, in the journal order.
+// The trades are grouped per instrument so that the hours of overlapping trades are decoded once.
+func Journal(ctx context.Context, args ArgsList, w io.Writer) error {
+	serverTime, err := bars.ParseAlignment(args.BrokerOffset)
+	if err != nil {
+		return errors.Wrap(err, "invalid broker-offset parameter")
+	}
+
+	f, err := os.Open(args.Journal)
+	if err != nil {
+		return errors.Wrap(err, "failed to open journal")
+	}
+	defer func(f *os.File) { _ = f.Close() }(f)
+
+	trades, err := readJournal(f, serverTime)
+	if err != nil {
+		return err
+	}
+
+	opt := AppOption{Offline: args.Offline, Revalidate: parseRevalidateArguments(args)}
+	if opt.Source, err = parseSourceArgument(args.Source); err != nil {
+		return err
+	}
+	if opt.Folder, err = filepath.Abs(args.Output); err != nil {
+		return fmt.Errorf("invalid destination folder")
+	}
+
+	reports := make([]journalReport, len(trades))
+	for _, indexes := range groupJournal(trades) {
+		metadata := trades[indexes[0]].metadata
+		instrumentTrades := make([]analysis.Trade, 0, len(indexes))
+		for _, i := range indexes {
+			instrumentTrades = append(instrumentTrades, trades[i].trade)
+		}
+
+		slog.Info("Analyzing trades", slog.String("symbol", metadata.Code()), slog.Int("count", len(indexes)))
+		excursions, aerr := analysis.AnalyzeAllContext(ctx, metadata, instrumentTrades, opt.Folder, opt.DatafeedOptions()...)
+		if aerr != nil {
+			return aerr
+		}
+		for j, i := range indexes {
+			reports[i] = newJournalReport(metadata, excursions[j], trades[i].serverTime)
+		}
+	}
+
+	if args.Json {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	}
+
+	return writeJournalCsv(w, reports)
+}
+
+// readJournal reads the trades, the header names the columns: symbol, side, open_time, open_price,
+// close_time, close_price and the optional sl, tp and offset. The offset overrides the broker offset of the row.
+func readJournal(r io.Reader, serverTime bars.Alignment) ([]journalTrade, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read journal header")
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"symbol", "side", "open_time", "open_price", "close_time", "close_price"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("journal is missing the [%s] column", name)
+		}
+	}
+
+	trades := make([]journalTrade, 0)
+	for line := 2; ; line++ {
+		record, rerr := reader.Read()
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return nil, errors.Wrap(rerr, "failed to read journal")
+		}
+
+		trade, terr := parseJournalRecord(record, columns, serverTime)
+		if terr != nil {
+			return nil, errors.Wrapf(terr, "invalid journal line [%d]", line)
+		}
+		trades = append(trades, trade)
+	}
+
+	return trades, nil
+}
+
+func parseJournalRecord(record []string, columns map[string]int, serverTime bars.Alignment) (t journalTrade, err error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	if t.metadata = journalInstrument(field("symbol")); t.metadata == nil {
+		return t, fmt.Errorf("unknown symbol [%s]", field("symbol"))
+	}
+
+	switch strings.ToLower(field("side")) {
+	case "buy", "long":
+		t.trade.Side = analysis.Buy
+	case "sell", "short":
+		t.trade.Side = analysis.Sell
+	default:
+		return t, fmt.Errorf("invalid side [%s], supported buy/sell", field("side"))
+	}
+
+	t.serverTime = serverTime
+	if offset := field("offset"); offset != "" {
+		if t.serverTime, err = bars.ParseAlignment(offset); err != nil {
+			return t, errors.Wrap(err, "invalid offset")
+		}
+	}
+
+	if t.trade.OpenTime, err = parseJournalTime(field("open_time"), t.serverTime); err != nil {
+		return
+	}
+	if t.trade.CloseTime, err = parseJournalTime(field("close_time"), t.serverTime); err != nil {
+		return
+	}
+	if t.trade.OpenPrice, err = parseJournalPrice(field("open_price"), true); err != nil {
+		return
+	}
+	if t.trade.ClosePrice, err = parseJournalPrice(field("close_price"), true); err != nil {
+		return
+	}
+	if t.trade.StopLoss, err = parseJournalPrice(field("sl"), false); err != nil {
+		return
+	}
+	t.trade.TakeProfit, err = parseJournalPrice(field("tp"), false)
+
+	return
+}
+
+// journalInstrument finds the instrument of a broker symbol, ignoring suffixes like EURUSD.m
+func journalInstrument(symbol string) *instrument.Metadata {
+	symbol = strings.ToUpper(symbol)
+	if metadata := instrument.GetMetadata(symbol); metadata != nil {
+		return metadata
+	}
+	if i := strings.IndexAny(symbol, ".#_"); i > 0 {
+		return instrument.GetMetadata(symbol[:i])
+	}
+
+	return nil
+}
+
+func parseJournalTime(s string, serverTime bars.Alignment) (time.Time, error) {
+	for _, layout := range journalTimeLayouts {
+		if wall, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return serverTime.FromWallClock(wall), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time [%s], expected like 2020.11.03 17:00:00", s)
+}
+
+func parseJournalPrice(s string, isRequired bool) (float64, error) {
+	if s == "" && !isRequired {
+		return 0, nil
+	}
+
+	price, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid price [%s]", s)
+	}
+
+	return price, nil
+}
+
+// groupJournal returns the indexes of the trades per instrument, instruments in the order of their first trade
+func groupJournal(trades []journalTrade) [][]int {
+	groups := make([][]int, 0)
+	byCode := make(map[string]int)
+	for i, t := range trades {
+		g, ok := byCode[t.metadata.Code()]
+		if !ok {
+			g = len(groups)
+			byCode[t.metadata.Code()] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	return groups
+}
+
+// newJournalReport with times in UTC, the csv report writes them in the broker time like the journal
+func newJournalReport(metadata *instrument.Metadata, e analysis.Excursion, serverTime bars.Alignment) journalReport {
+	optionalTime := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		t = t.UTC()
+		return &t
+	}
+
+	r := journalReport{
+		Symbol:     metadata.Code(),
+		Side:       e.Trade.Side.String(),
+		OpenTime:   e.Trade.OpenTime.UTC(),
+		OpenPrice:  e.Trade.OpenPrice,
+		CloseTime:  e.Trade.CloseTime.UTC(),
+		ClosePrice: e.Trade.ClosePrice,
+		Ticks:      e.Ticks,
+		serverTime: serverTime,
+	}
+	if e.ExitTick == nil {
+		r.Error = "no tick after the close time"
+		return r
+	}
+
+	r.MAEPoints = analysis.Points(metadata, e.MAE)
+	r.MFEPoints = analysis.Points(metadata, e.MFE)
+	r.DrawdownBeforeMFEPoints = analysis.Points(metadata, e.DrawdownBeforeMFE)
+	r.MFETime = optionalTime(e.MFETime)
+	r.TimeToMFE = e.TimeToMFE.String()
+	r.EntrySlippagePoints = analysis.Points(metadata, e.EntrySlippage)
+	r.ExitSlippagePoints = analysis.Points(metadata, e.ExitSlippage)
+	r.SpreadCostPoints = analysis.Points(metadata, e.SpreadCost)
+	r.StopLossTime = optionalTime(e.StopLossTime)
+	r.TakeProfitTime = optionalTime(e.TakeProfitTime)
+
+	return r
+}
+
+func writeJournalCsv(w io.Writer, reports []journalReport) error {
+	const layout = "2006.01.02 15:04:05"
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{
+		"symbol", "side", "open_time", "open_price", "close_time", "close_price", "ticks",
+		"mae_points", "mfe_points", "drawdown_before_mfe_points", "mfe_time", "time_to_mfe",
+		"entry_slippage_points", "exit_slippage_points", "spread_cost_points", "sl_time", "tp_time", "error",
+	})
+	for _, r := range reports {
+		brokerTime := func(t *time.Time) string {
+			if t == nil {
+				return ""
+			}
+			return r.serverTime.WallClock(*t).Format(layout)
+		}
+		_ = cw.Write([]string{
+			r.Symbol,
+			r.Side,
+			brokerTime(&r.OpenTime),
+			strconv.FormatFloat(r.OpenPrice, 'f', -1, 64),
+			brokerTime(&r.CloseTime),
+			strconv.FormatFloat(r.ClosePrice, 'f', -1, 64),
+			strconv.Itoa(r.Ticks),
+			strconv.Itoa(r.MAEPoints),
+			strconv.Itoa(r.MFEPoints),
+			strconv.Itoa(r.DrawdownBeforeMFEPoints),
+			brokerTime(r.MFETime),
+			r.TimeToMFE,
+			strconv.Itoa(r.EntrySlippagePoints),
+			strconv.Itoa(r.ExitSlippagePoints),
+			strconv.Itoa(r.SpreadCostPoints),
+			brokerTime(r.StopLossTime),
+			brokerTime(r.TakeProfitTime),
+			r.Error,
+		})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/edward-yakop/go-duka/api/bars"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	mirror := t.TempDir()
+	dayHour := time.Date(2020, time.November, 3, 15, 0, 0, 0, time.UTC)
+	bi5test.WriteMirror(t, mirror, "GBPJPY", dayHour, bi5test.Encode(t, dayHour, 1000,
+		bi5test.Tick("GBPJPY", dayHour, 136.302, 136.300),
+		bi5test.Tick("GBPJPY", dayHour.Add(time.Second), 136.252, 136.250),
+		bi5test.Tick("GBPJPY", dayHour.Add(2*time.Second), 136.452, 136.450),
+		bi5test.Tick("GBPJPY", dayHour.Add(3*time.Second), 136.402, 136.400),
+	))
+
+	// Broker times are GMT+2, the second trade overrides the offset
+	journalPath := filepath.Join(t.TempDir(), "trades.csv")
+	assert.NoError(t, os.WriteFile(journalPath, []byte(
+		"Symbol,Side,Open_Time,Open_Price,Close_Time,Close_Price,SL,TP,Offset\n"+
+			"GBPJPY.m,buy,2020.11.03 17:00:00,136.302,2020.11.03 17:00:03,136.400,136.200,136.440,\n"+
+			"gbpjpy,sell,2020-11-03 15:00:01,136.250,2020-11-03 15:00:02,136.452,,,UTC\n",
+	), 0644))
+
+	args := ArgsList{
+		Journal:      journalPath,
+		BrokerOffset: "+02:00",
+		Output:       t.TempDir(),
+		Source:       (&url.URL{Scheme: "file", Path: filepath.ToSlash(mirror)}).String(),
+	}
+
+	w := new(bytes.Buffer)
+	if !assert.NoError(t, Journal(context.Background(), args, w)) {
+		t.FailNow()
+	}
+	records, err := csv.NewReader(w).ReadAll()
+	if !assert.NoError(t, err) || !assert.Len(t, records, 3) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{
+		"GBPJPY", "buy", "2020.11.03 17:00:00", "136.302", "2020.11.03 17:00:03", "136.4", "4",
+		"-52", "148", "-52", "2020.11.03 17:00:02", "2s", "0", "0", "2", "", "2020.11.03 17:00:02", "",
+	}, records[1])
+	assert.Equal(t, "2020.11.03 15:00:01", records[2][2])
+	assert.Equal(t, "-202", records[2][7])
+
+	args.Json = true
+	w.Reset()
+	if assert.NoError(t, Journal(context.Background(), args, w)) {
+		var reports []journalReport
+		assert.NoError(t, json.Unmarshal(w.Bytes(), &reports))
+		if assert.Len(t, reports, 2) {
+			assert.Equal(t, dayHour, reports[0].OpenTime)
+			assert.Equal(t, dayHour.Add(2*time.Second), *reports[0].TakeProfitTime)
+		}
+	}
+
+	args.Journal = filepath.Join(t.TempDir(), "missing.csv")
+	assert.Error(t, Journal(context.Background(), args, w))
+}
+
+func TestReadJournal_Errors(t *testing.T) {
+	_, err := readJournal(bytes.NewBufferString("symbol,side\n"), bars.Alignment{})
+	assert.ErrorContains(t, err, "open_time")
+
+	_, err = readJournal(bytes.NewBufferString(
+		"symbol,side,open_time,open_price,close_time,close_price\n"+
+			"NOPE,buy,2020.11.03 17:00:00,1,2020.11.03 17:00:01,1\n"), bars.Alignment{})
+	assert.ErrorContains(t, err, "line [2]")
+}
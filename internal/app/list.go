@@ -0,0 +1,88 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/pkg/errors"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+type listedInstrument struct {
+	Code          string    `json:"code"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	Group         string    `json:"group"`
+	BaseCurrency  string    `json:"baseCurrency"`
+	QuoteCurrency string    `json:"quoteCurrency"`
+	Digits        int       `json:"digits"`
+	PipSize       float64   `json:"pipSize"`
+	TicksFrom     time.Time `json:"ticksFrom"`
+}
+
+// List prints the instruments matching the query, group, currency and available arguments
+func List(args ArgsList, w io.Writer) error {
+	filter, err := parseFilterArguments(args)
+	if err != nil {
+		return err
+	}
+
+	matches := instrument.Find(filter)
+	if args.Json {
+		listed := make([]listedInstrument, 0, len(matches))
+		for _, m := range matches {
+			listed = append(listed, listedInstrument{
+				Code:          m.Code(),
+				Name:          m.Name(),
+				Description:   m.Description(),
+				Group:         string(m.Group()),
+				BaseCurrency:  m.BaseCurrency(),
+				QuoteCurrency: m.QuoteCurrency(),
+				Digits:        m.Digits(),
+				PipSize:       m.PipSize(),
+				TicksFrom:     m.StartHourForTicks().UTC(),
+			})
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(listed)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CODE\tNAME\tGROUP\tDIGITS\tTICKS FROM\tDESCRIPTION")
+	for _, m := range matches {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			m.Code(),
+			m.Name(),
+			m.Group(),
+			m.Digits(),
+			m.StartHourForTicks().UTC().Format("2006-01-02:15H"),
+			m.Description(),
+		)
+	}
+
+	return tw.Flush()
+}
+
+func parseFilterArguments(args ArgsList) (filter instrument.Filter, err error) {
+	filter.Query = args.Query
+	filter.Currency = args.Currency
+
+	if args.Group != "" {
+		var ok bool
+		if filter.Group, ok = instrument.ParseGroup(args.Group); !ok {
+			return filter, fmt.Errorf("invalid group parameter [%s]", args.Group)
+		}
+	}
+
+	if args.Available != "" {
+		if filter.AvailableAt, err = parseDateArgument(args.Available); err != nil {
+			return filter, errors.Wrap(err, "invalid available parameter")
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"fmt"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/internal/bi5"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Markers lists or clears the .notFound/.empty markers of the symbol between start and end arguments
+func Markers(args ArgsList, w io.Writer) error {
+	metadata := instrument.GetMetadata(args.Symbol)
+	if metadata == nil {
+		return invalidSymbol(args, args.Symbol)
+	}
+
+	opt := AppOption{}
+	if err := handleTimeArguments(args, &opt); err != nil {
+		return err
+	}
+
+	folder, err := filepath.Abs(args.Output)
+	if err != nil {
+		return fmt.Errorf("invalid destination folder")
+	}
+
+	// The end is exclusive like for the exports, the helpers include their last hour
+	last := opt.End.Add(-time.Hour)
+	var markers []bi5.Marker
+	action := strings.ToLower(args.Markers)
+	switch action {
+	case "list":
+		markers, err = bi5.ListMarkers(folder, metadata.Code(), opt.Start, last)
+	case "clear":
+		markers, err = bi5.ClearMarkers(folder, metadata.Code(), opt.Start, last)
+	default:
+		return fmt.Errorf("invalid markers parameter [%s], supported list/clear", args.Markers)
+	}
+
+	for _, m := range markers {
+		_, _ = fmt.Fprintf(w, "%s %s %-8s created %s\n",
+			m.InstrumentCode,
+			m.DayHour.Format("2006-01-02:15H"),
+			m.Kind,
+			m.Created.UTC().Format("2006-01-02 15:04:05"),
+		)
+	}
+	if action == "clear" {
+		_, _ = fmt.Fprintf(w, "Cleared %d markers\n", len(markers))
+	}
+
+	return err
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"bytes"
+	"github.com/edward-yakop/go-duka/internal/bi5"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMarkers_EndIsExclusive(t *testing.T) {
+	folder := t.TempDir()
+	lastHour := time.Date(2021, time.January, 4, 23, 0, 0, 0, time.UTC)
+	nextDay := lastHour.Add(time.Hour)
+	for _, dayHour := range []time.Time{lastHour, nextDay} {
+		path := bi5.BiFilePathTime(folder, "EURUSD", dayHour) + bi5.MarkerNotFound.Ext()
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+
+	args := ArgsList{
+		Symbol:  "EURUSD",
+		Output:  folder,
+		Start:   "2021-01-04",
+		End:     "2021-01-05",
+		Markers: "clear",
+	}
+	var out bytes.Buffer
+	if assert.NoError(t, Markers(args, &out)) {
+		assert.Contains(t, out.String(), "Cleared 1 markers")
+	}
+	assert.NoFileExists(t, bi5.BiFilePathTime(folder, "EURUSD", lastHour)+bi5.MarkerNotFound.Ext())
+	assert.FileExists(t, bi5.BiFilePathTime(folder, "EURUSD", nextDay)+bi5.MarkerNotFound.Ext())
+}
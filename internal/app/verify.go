@@ -0,0 +1,37 @@
+package app
+
+import (
+	"context"
+	"github.com/edward-yakop/go-duka/internal/bi5"
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// Verify scans the bi5 cache of the output folder and quarantines corrupted files.
+// The scan is limited to the symbol argument if it's set.
+func Verify(ctx context.Context, args ArgsList) (bi5.VerifyResult, error) {
+	folder, err := filepath.Abs(args.Output)
+	if err != nil {
+		return bi5.VerifyResult{}, err
+	}
+
+	result, err := bi5.VerifyCache(ctx, folder, strings.TrimSpace(args.Symbol), func(filePath string, verifyErr error, quarantinePath string) {
+		if verifyErr != nil {
+			slog.Warn("Quarantined corrupted file",
+				slog.String("path", filePath),
+				slog.String("quarantine", quarantinePath),
+				slog.Any("error", verifyErr),
+			)
+		} else {
+			slog.Debug("Verified", slog.String("path", filePath))
+		}
+	})
+	slog.Info("Verify completed",
+		slog.String("folder", folder),
+		slog.Int("checked", result.Checked),
+		slog.Int("quarantined", result.Quarantined),
+	)
+
+	return result, err
+}
@@ -3,12 +3,15 @@ package bi5
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/binary"
+	"github.com/edward-yakop/go-duka/api/datafeed"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/api/tickdata"
 	"github.com/edward-yakop/go-duka/internal/misc"
 	"github.com/pkg/errors"
 	"io"
+	"iter"
 	"os"
 	"time"
 
@@ -19,6 +22,7 @@ import (
 const ext = "bi5"
 
 var httpDownload = core.NewDownloader()
+var fileDownload = core.NewFileDownloader()
 
 const (
 	TICK_BYTES = 20
@@ -32,6 +36,9 @@ type Bi5 struct {
 	targetFilePath string
 	save           bool
 	downloader     *Downloader
+	// legs of a synthetic instrument hour
+	legs []*Bi5
+	opts []datafeed.Option
 }
 
 func (b Bi5) DayHour() time.Time {
@@ -43,18 +50,28 @@ func (b Bi5) InstrumentCode() string {
 }
 
 // New create an bi5 saver
-func New(dayHour time.Time, metadata *instrument.Metadata, downloadFolderPath string) *Bi5 {
+func New(dayHour time.Time, metadata *instrument.Metadata, downloadFolderPath string, opts ...datafeed.Option) *Bi5 {
 	dayHour = dayHour.UTC()
 	y, m, d := dayHour.Date()
 
 	beginHour := time.Date(y, m, d, dayHour.Hour(), 0, 0, 0, time.UTC)
 	endHour := beginHour.Add(time.Hour).Add(-1)
+	var legs []*Bi5
+	if synthetic := metadata.Synthetic(); synthetic != nil {
+		legs = []*Bi5{
+			New(dayHour, synthetic.A, downloadFolderPath, opts...),
+			New(dayHour, synthetic.B, downloadFolderPath, opts...),
+		}
+	}
+
 	return &Bi5{
 		targetFilePath: BiFilePath(downloadFolderPath, metadata.Code(), y, int(m), d, dayHour.Hour()),
 		dayHour:        beginHour,
 		endDayHour:     endHour,
 		metadata:       metadata,
-		downloader:     NewDownloader(downloadFolderPath),
+		downloader:     NewDownloader(downloadFolderPath, opts...),
+		legs:           legs,
+		opts:           opts,
 	}
 }
 
@@ -179,10 +196,23 @@ func read[T any](existingErr error, buf *bytes.Buffer, field string) (r T, err e
 
 // Download from dukascopy
 func (b Bi5) Download() error {
-	return b.downloader.Download(b.InstrumentCode(), b.dayHour)
+	return b.DownloadContext(context.Background())
+}
+
+// DownloadContext from dukascopy, the download is aborted once ctx is done
+func (b Bi5) DownloadContext(ctx context.Context) error {
+	if b.legs != nil {
+		return b.downloadLegs(ctx)
+	}
+
+	return b.downloader.DownloadContext(ctx, b.InstrumentCode(), b.dayHour)
 }
 
 func (b Bi5) EachTick(it tickdata.TickIterator) {
+	if b.legs != nil {
+		b.eachSyntheticTick(it)
+		return
+	}
 	if !misc.IsFileExists(b.targetFilePath) {
 		return
 	}
@@ -198,7 +228,7 @@ func (b Bi5) EachTick(it tickdata.TickIterator) {
 
 	reader, lzmaErr := lzma.NewReader(bufio.NewReader(f))
 	if lzmaErr != nil {
-		err = errors.Wrapf(err, "failed to create file [%s] reader", b.targetFilePath)
+		err = errors.Wrapf(lzmaErr, "failed to create file [%s] reader", b.targetFilePath)
 		it(nil, err)
 
 		return
@@ -209,20 +239,24 @@ func (b Bi5) EachTick(it tickdata.TickIterator) {
 	var tick *tickdata.TickData
 	for {
 		tick = nil
-		bytesCount, err = reader.Read(bytesArr[:])
+		bytesCount, err = io.ReadFull(reader, bytesArr[:])
 		if err == io.EOF {
 			err = nil
 
 			break
 		}
 
-		if bytesCount != TICK_BYTES || err != nil {
+		if err != nil {
+			// The rest of the file can't be decoded
 			err = errors.Wrapf(err, "LZMA decode failed: [%d] for file [%s]", bytesCount, b.targetFilePath)
-		} else {
-			tick, err = b.decodeTickData(bytesArr[:], b.InstrumentCode(), b.dayHour)
-			if err != nil {
-				err = errors.Wrapf(err, "decode tick data failed for file [%s]", b.targetFilePath)
-			}
+			it(nil, err)
+
+			return
+		}
+
+		tick, err = b.decodeTickData(bytesArr[:], b.InstrumentCode(), b.dayHour)
+		if err != nil {
+			err = errors.Wrapf(err, "decode tick data failed for file [%s]", b.targetFilePath)
 		}
 
 		if !it(tick, err) {
@@ -230,3 +264,12 @@ func (b Bi5) EachTick(it tickdata.TickIterator) {
 		}
 	}
 }
+
+// All yields the ticks of the hour file, breaking out of the loop closes the file
+func (b Bi5) All() iter.Seq2[*tickdata.TickData, error] {
+	return func(yield func(*tickdata.TickData, error) bool) {
+		b.EachTick(func(tick *tickdata.TickData, err error) bool {
+			return yield(tick, err)
+		})
+	}
+}
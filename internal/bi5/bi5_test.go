@@ -1,10 +1,16 @@
 package bi5
 
 import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -50,3 +56,435 @@ func createEmptyDir(t *testing.T) string {
 
 	return dir
 }
+
+func TestDownloader_Source(t *testing.T) {
+	dayHour := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	mirror := createEmptyDir(t)
+	content := bi5test.Encode(t, dayHour, 100000,
+		bi5test.Tick("EURUSD", dayHour.Add(time.Second), 1.22501, 1.22499),
+	)
+	bi5test.WriteMirror(t, mirror, "EURUSD", dayHour, content)
+
+	server := httptest.NewServer(http.FileServer(http.Dir(mirror)))
+	t.Cleanup(server.Close)
+
+	absMirror, err := filepath.Abs(mirror)
+	assert.NoError(t, err)
+
+	sources := map[string]string{
+		"http": server.URL,
+		"file": "file://" + filepath.ToSlash(absMirror),
+	}
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			folder := createEmptyDir(t)
+			d := NewDownloader(folder, datafeed.WithSource(source))
+
+			assert.NoError(t, d.Download("EURUSD", dayHour))
+			downloaded, err := os.ReadFile(BiFilePathTime(folder, "EURUSD", dayHour))
+			if assert.NoError(t, err) {
+				assert.Equal(t, content, downloaded)
+			}
+
+			nextHour := dayHour.Add(time.Hour)
+			assert.NoError(t, d.Download("EURUSD", nextHour))
+			assert.FileExists(t, BiFilePathTime(folder, "EURUSD", nextHour)+".notFound")
+			assert.NoFileExists(t, BiFilePathTime(folder, "EURUSD", nextHour))
+		})
+	}
+}
+
+func TestDownloader_DownloadContext_Cancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+
+	folder := createEmptyDir(t)
+	d := NewDownloader(folder, datafeed.WithSource(server.URL))
+	dayHour := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	err := d.DownloadContext(ctx, "EURUSD", dayHour)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), 5*time.Second)
+	assert.NoFileExists(t, BiFilePathTime(folder, "EURUSD", dayHour))
+}
+
+func TestDownloader_RejectsCorruptedDownload(t *testing.T) {
+	dayHour := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	content := bi5test.Encode(t, dayHour, 100000,
+		bi5test.Tick("EURUSD", dayHour.Add(time.Second), 1.22501, 1.22499),
+		bi5test.Tick("EURUSD", dayHour.Add(2*time.Second), 1.22502, 1.22498),
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content[:len(content)/2])
+	}))
+	t.Cleanup(server.Close)
+
+	folder := createEmptyDir(t)
+	d := NewDownloader(folder, datafeed.WithSource(server.URL))
+	assert.Error(t, d.Download("EURUSD", dayHour))
+
+	target := BiFilePathTime(folder, "EURUSD", dayHour)
+	assert.NoFileExists(t, target)
+	entries, err := os.ReadDir(filepath.Dir(target))
+	if assert.NoError(t, err) {
+		assert.Empty(t, entries, "temporary files must be removed")
+	}
+}
+
+func TestVerifyCache(t *testing.T) {
+	dayHour := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	content := bi5test.Encode(t, dayHour, 100000,
+		bi5test.Tick("EURUSD", dayHour.Add(time.Second), 1.22501, 1.22499),
+	)
+
+	folder := createEmptyDir(t)
+	valid := BiFilePathTime(folder, "EURUSD", dayHour)
+	truncated := filepath.Clean(BiFilePathTime(folder, "EURUSD", dayHour.Add(time.Hour)))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(valid), 0755))
+	assert.NoError(t, os.WriteFile(valid, content, 0644))
+	assert.NoError(t, os.WriteFile(truncated, content[:len(content)-3], 0644))
+
+	quarantined := map[string]string{}
+	result, err := VerifyCache(context.Background(), folder, "", func(filePath string, verifyErr error, quarantinePath string) {
+		if verifyErr != nil {
+			quarantined[filePath] = quarantinePath
+		}
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, VerifyResult{Checked: 2, Quarantined: 1}, result)
+	assert.Contains(t, quarantined, truncated)
+	assert.FileExists(t, quarantined[truncated])
+	assert.NoFileExists(t, truncated)
+	assert.FileExists(t, valid)
+}
+
+func TestDownloader_RevalidateMarkers(t *testing.T) {
+	dayHour := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	mirror := createEmptyDir(t)
+	bi5test.WriteMirror(t, mirror, "EURUSD", dayHour, bi5test.Encode(t, dayHour, 100000,
+		bi5test.Tick("EURUSD", dayHour.Add(time.Second), 1.22501, 1.22499),
+	))
+	absMirror, err := filepath.Abs(mirror)
+	assert.NoError(t, err)
+	source := datafeed.WithSource("file://" + filepath.ToSlash(absMirror))
+
+	tests := map[string]struct {
+		markerTime    time.Time
+		policy        datafeed.RevalidatePolicy
+		isRevalidated bool
+	}{
+		"created before the hour was published": {
+			markerTime:    dayHour.Add(30 * time.Minute),
+			policy:        datafeed.DefaultRevalidatePolicy,
+			isRevalidated: true,
+		},
+		"created long after the hour": {
+			markerTime:    dayHour.Add(30 * 24 * time.Hour),
+			policy:        datafeed.DefaultRevalidatePolicy,
+			isRevalidated: false,
+		},
+		"recent hour": {
+			markerTime:    dayHour.Add(30 * 24 * time.Hour),
+			policy:        datafeed.RevalidatePolicy{Recent: time.Since(dayHour) + time.Hour},
+			isRevalidated: true,
+		},
+		"disabled": {
+			markerTime:    dayHour.Add(30 * time.Minute),
+			policy:        datafeed.RevalidatePolicy{},
+			isRevalidated: false,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			folder := createEmptyDir(t)
+			target := BiFilePathTime(folder, "EURUSD", dayHour)
+			marker := target + MarkerNotFound.Ext()
+			assert.NoError(t, os.MkdirAll(filepath.Dir(target), 0755))
+			assert.NoError(t, os.WriteFile(marker, nil, 0644))
+			assert.NoError(t, os.Chtimes(marker, test.markerTime, test.markerTime))
+
+			d := NewDownloader(folder, source, datafeed.WithRevalidatePolicy(test.policy))
+			assert.NoError(t, d.Download("EURUSD", dayHour))
+
+			if test.isRevalidated {
+				assert.FileExists(t, target)
+				assert.NoFileExists(t, marker)
+			} else {
+				assert.NoFileExists(t, target)
+				assert.FileExists(t, marker)
+			}
+		})
+	}
+}
+
+func TestListAndClearMarkers(t *testing.T) {
+	dayHour := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	folder := createEmptyDir(t)
+	emptyMirror, err := filepath.Abs(createEmptyDir(t))
+	assert.NoError(t, err)
+	d := NewDownloader(folder, datafeed.WithSource("file://"+filepath.ToSlash(emptyMirror)))
+	assert.NoError(t, d.Download("EURUSD", dayHour))
+	assert.NoError(t, d.Download("EURUSD", dayHour.Add(time.Hour)))
+	assert.NoError(t, d.Download("EURUSD", dayHour.Add(5*time.Hour)))
+
+	markers, err := ListMarkers(folder, "eurusd", dayHour, dayHour.Add(2*time.Hour))
+	assert.NoError(t, err)
+	if assert.Len(t, markers, 2) {
+		assert.Equal(t, dayHour, markers[0].DayHour)
+		assert.Equal(t, MarkerNotFound, markers[0].Kind)
+		assert.Equal(t, "EURUSD", markers[0].InstrumentCode)
+	}
+
+	cleared, err := ClearMarkers(folder, "EURUSD", dayHour, dayHour.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, cleared, 2)
+
+	markers, err = ListMarkers(folder, "EURUSD", dayHour, dayHour.Add(24*time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, markers, 1)
+}
+
+func TestDownloader_Offline(t *testing.T) {
+	dayHour := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("offline downloader requested [%s]", r.URL)
+	}))
+	t.Cleanup(server.Close)
+
+	folder := createEmptyDir(t)
+	cached := BiFilePathTime(folder, "EURUSD", dayHour)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(cached), 0755))
+	assert.NoError(t, os.WriteFile(cached, bi5test.Encode(t, dayHour, 100000), 0644))
+	// Stale markers are still respected, they can't be checked without network
+	marker := BiFilePathTime(folder, "EURUSD", dayHour.Add(time.Hour)) + MarkerEmpty.Ext()
+	assert.NoError(t, os.WriteFile(marker, nil, 0644))
+	assert.NoError(t, os.Chtimes(marker, dayHour, dayHour))
+
+	d := NewDownloader(folder, datafeed.WithSource(server.URL), datafeed.WithOffline())
+	assert.NoError(t, d.Download("EURUSD", dayHour))
+	assert.NoError(t, d.Download("EURUSD", dayHour.Add(time.Hour)))
+
+	err := d.Download("EURUSD", dayHour.Add(2*time.Hour))
+	var notCached *datafeed.ErrNotCached
+	if assert.ErrorAs(t, err, &notCached) {
+		assert.Equal(t, "EURUSD", notCached.Symbol)
+		assert.Equal(t, dayHour.Add(2*time.Hour), notCached.Hour)
+	}
+}
+
+func TestVerifyCache_Candles(t *testing.T) {
+	content := bi5test.EncodeCandles(t, 100000,
+		bi5test.CandleRecord{Open: 1.22, High: 1.23, Low: 1.21, Close: 1.225, Volume: 1},
+	)
+	ticks := bi5test.Encode(t, time.Time{}, 100000, bi5test.Tick("EURUSD", time.Time{}, 1.22501, 1.22499))
+
+	folder := createEmptyDir(t)
+	year := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	valid := CandleFilePath(folder, "EURUSD", CandleBid, CandleDay, year)
+	invalid := filepath.Clean(CandleFilePath(folder, "EURUSD", CandleAsk, CandleDay, year))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(valid), 0755))
+	assert.NoError(t, os.WriteFile(valid, content, 0644))
+	// A tick record is not a multiple of the candle record size
+	assert.NoError(t, os.WriteFile(invalid, ticks, 0644))
+
+	quarantined := map[string]string{}
+	result, err := VerifyCache(context.Background(), folder, "EURUSD", func(filePath string, verifyErr error, quarantinePath string) {
+		if verifyErr != nil {
+			quarantined[filePath] = quarantinePath
+		}
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, VerifyResult{Checked: 2, Quarantined: 1}, result)
+	assert.Contains(t, quarantined, invalid)
+	assert.FileExists(t, valid)
+}
+
+func TestBi5_All(t *testing.T) {
+	dayHour := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	folder := createEmptyDir(t)
+	target := BiFilePathTime(folder, "EURUSD", dayHour)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(target), 0755))
+	assert.NoError(t, os.WriteFile(target, bi5test.Encode(t, dayHour, 100000,
+		bi5test.Tick("EURUSD", dayHour.Add(time.Second), 1.22501, 1.22499),
+		bi5test.Tick("EURUSD", dayHour.Add(2*time.Second), 1.22502, 1.22498),
+		bi5test.Tick("EURUSD", dayHour.Add(3*time.Second), 1.22503, 1.22497),
+	), 0644))
+
+	bi := New(dayHour, instrument.GetMetadata("EURUSD"), folder)
+	var times []time.Time
+	for tick, err := range bi.All() {
+		assert.NoError(t, err)
+		times = append(times, tick.UTC())
+		if len(times) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []time.Time{dayHour.Add(time.Second), dayHour.Add(2 * time.Second)}, times)
+
+	// A truncated file yields a single error
+	assert.NoError(t, os.WriteFile(target, []byte("not lzma"), 0644))
+	errCount := 0
+	for tick, err := range bi.All() {
+		assert.Nil(t, tick)
+		assert.Error(t, err)
+		errCount++
+	}
+	assert.Equal(t, 1, errCount)
+}
+
+func TestBi5_Synthetic(t *testing.T) {
+	dayHour := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	mirror := createEmptyDir(t)
+	bi5test.WriteMirror(t, mirror, "EURUSD", dayHour, bi5test.Encode(t, dayHour, 100000,
+		bi5test.Tick("EURUSD", dayHour.Add(time.Second), 1.10002, 1.10000),
+		bi5test.Tick("EURUSD", dayHour.Add(3*time.Second), 1.10012, 1.10010),
+		bi5test.Tick("EURUSD", dayHour.Add(4*time.Second), 1.10022, 1.10020),
+	))
+	bi5test.WriteMirror(t, mirror, "USDNOK", dayHour, bi5test.Encode(t, dayHour, 100000,
+		bi5test.Tick("USDNOK", dayHour.Add(2*time.Second), 10.00050, 10.00000),
+		bi5test.Tick("USDNOK", dayHour.Add(4*time.Second), 10.00150, 10.00100),
+	))
+
+	eurnok, err := instrument.NewSynthetic("EURNOK", instrument.GetMetadata("EURUSD"), instrument.Multiply, instrument.GetMetadata("USDNOK"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	folder := createEmptyDir(t)
+	bi := New(dayHour, eurnok, folder, bi5test.FileSource(t, mirror))
+	assert.NoError(t, bi.Download())
+	assert.FileExists(t, BiFilePathTime(folder, "EURUSD", dayHour))
+	assert.FileExists(t, BiFilePathTime(folder, "USDNOK", dayHour))
+
+	ticks, err := bi.Ticks()
+	if !assert.NoError(t, err) || !assert.Len(t, ticks, 3) {
+		t.FailNow()
+	}
+
+	// No tick until both legs quoted, the legs ticking at the same time yield a single tick
+	assert.Equal(t, "EURNOK", ticks[0].Symbol)
+	assert.Equal(t, dayHour.Add(2*time.Second), ticks[0].UTC())
+	assert.Equal(t, 11.0, ticks[0].Bid)
+	assert.Equal(t, 11.00076, ticks[0].Ask)
+	assert.Equal(t, dayHour.Add(3*time.Second), ticks[1].UTC())
+	assert.Equal(t, 11.001, ticks[1].Bid)
+	assert.Equal(t, dayHour.Add(4*time.Second), ticks[2].UTC())
+	assert.Equal(t, 11.0031, ticks[2].Bid, "1.10020 * 10.00100 rounded down")
+	assert.Equal(t, 11.00386, ticks[2].Ask, "1.10022 * 10.00150 rounded up")
+}
+
+func TestBi5_SyntheticCarriesOverHours(t *testing.T) {
+	hour1 := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	hour2, hour3 := hour1.Add(time.Hour), hour1.Add(2*time.Hour)
+	mirror := t.TempDir()
+	bi5test.WriteMirror(t, mirror, "EURUSD", hour1, bi5test.Encode(t, hour1, 100000,
+		bi5test.Tick("EURUSD", hour1.Add(time.Second), 1.10002, 1.10000),
+	))
+	bi5test.WriteMirror(t, mirror, "USDNOK", hour1, bi5test.Encode(t, hour1, 100000,
+		bi5test.Tick("USDNOK", hour1.Add(2*time.Second), 10.00050, 10.00000),
+	))
+	// USDNOK has no file in the second hour
+	bi5test.WriteMirror(t, mirror, "EURUSD", hour2, bi5test.Encode(t, hour2, 100000,
+		bi5test.Tick("EURUSD", hour2.Add(time.Second), 1.10012, 1.10010),
+	))
+	// USDNOK ticks first in the third hour
+	bi5test.WriteMirror(t, mirror, "EURUSD", hour3, bi5test.Encode(t, hour3, 100000,
+		bi5test.Tick("EURUSD", hour3.Add(2*time.Second), 1.10022, 1.10020),
+	))
+	bi5test.WriteMirror(t, mirror, "USDNOK", hour3, bi5test.Encode(t, hour3, 100000,
+		bi5test.Tick("USDNOK", hour3.Add(time.Second), 10.00150, 10.00100),
+	))
+
+	eurnok, err := instrument.NewSynthetic("EURNOK", instrument.GetMetadata("EURUSD"), instrument.Multiply, instrument.GetMetadata("USDNOK"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	folder := t.TempDir()
+	source := bi5test.FileSource(t, mirror)
+	ticksOf := func(dayHour time.Time) []*tickdata.TickData {
+		bi := New(dayHour, eurnok, folder, source)
+		if !assert.NoError(t, bi.Download()) {
+			t.FailNow()
+		}
+		ticks, err := bi.Ticks()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		return ticks
+	}
+
+	// The second hour is downloaded first, the first hour is looked back for the USDNOK quote
+	ticks := ticksOf(hour2)
+	if assert.Len(t, ticks, 1) {
+		assert.Equal(t, hour2.Add(time.Second), ticks[0].UTC())
+		assert.Equal(t, 11.001, ticks[0].Bid, "1.10010 * 10.00000")
+	}
+	assert.FileExists(t, BiFilePathTime(folder, "USDNOK", hour1))
+
+	ticks = ticksOf(hour3)
+	if assert.Len(t, ticks, 2) {
+		assert.Equal(t, hour3.Add(time.Second), ticks[0].UTC())
+		assert.Equal(t, 11.0021, ticks[0].Bid, "1.10010 * 10.00100, EURUSD of the second hour")
+		assert.Equal(t, hour3.Add(2*time.Second), ticks[1].UTC())
+		assert.Equal(t, 11.0031, ticks[1].Bid)
+	}
+}
+
+func TestBi5_SyntheticPreviousHoursNotDownloaded(t *testing.T) {
+	hour1 := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	hour2 := hour1.Add(time.Hour)
+	mirror := t.TempDir()
+	bi5test.WriteMirror(t, mirror, "EURUSD", hour1, bi5test.Encode(t, hour1, 100000,
+		bi5test.Tick("EURUSD", hour1.Add(time.Second), 1.10002, 1.10000),
+	))
+	bi5test.WriteMirror(t, mirror, "USDNOK", hour1, []byte("not lzma"))
+	bi5test.WriteMirror(t, mirror, "EURUSD", hour2, bi5test.Encode(t, hour2, 100000,
+		bi5test.Tick("EURUSD", hour2.Add(time.Second), 1.10012, 1.10010),
+		bi5test.Tick("EURUSD", hour2.Add(3*time.Second), 1.10022, 1.10020),
+	))
+	bi5test.WriteMirror(t, mirror, "USDNOK", hour2, bi5test.Encode(t, hour2, 100000,
+		bi5test.Tick("USDNOK", hour2.Add(2*time.Second), 10.00050, 10.00000),
+	))
+
+	eurnok, err := instrument.NewSynthetic("EURNOK", instrument.GetMetadata("EURUSD"), instrument.Multiply, instrument.GetMetadata("USDNOK"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// Only the hour of the legs is cached, the previous hours can't be downloaded offline
+	offline := t.TempDir()
+	for _, code := range []string{"EURUSD", "USDNOK"} {
+		assert.NoError(t, New(hour2, instrument.GetMetadata(code), offline, bi5test.FileSource(t, mirror)).Download())
+	}
+	// The previous USDNOK hour is corrupted
+	folder := t.TempDir()
+
+	for name, bi := range map[string]*Bi5{
+		"offline":   New(hour2, eurnok, offline, datafeed.WithOffline()),
+		"corrupted": New(hour2, eurnok, folder, bi5test.FileSource(t, mirror)),
+	} {
+		assert.NoError(t, bi.Download(), name)
+		ticks, err := bi.Ticks()
+		if assert.NoError(t, err, name) && assert.Len(t, ticks, 2, name) {
+			// No USDNOK quote to carry over, the first tick is once both legs quoted
+			assert.Equal(t, hour2.Add(2*time.Second), ticks[0].UTC(), name)
+			assert.Equal(t, 11.001, ticks[0].Bid, name)
+			assert.Equal(t, hour2.Add(3*time.Second), ticks[1].UTC(), name)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package bi5test provides utilities to craft dukascopy bi5 files for tests
+package bi5test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edward-yakop/go-duka/api/datafeed"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// Encode ticks of the given hour into lzma compressed bi5 content
+func Encode(t testing.TB, dayHour time.Time, decimalFactor float64, ticks ...*tickdata.TickData) []byte {
+	t.Helper()
+
+	raw := new(bytes.Buffer)
+	hourMs := dayHour.UTC().Unix() * 1000
+	for _, tick := range ticks {
+		record := struct {
+			TimeMs    int32
+			Ask       int32
+			Bid       int32
+			VolumeAsk float32
+			VolumeBid float32
+		}{
+			TimeMs:    int32(tick.Timestamp - hourMs),
+			Ask:       int32(math.Round(tick.Ask * decimalFactor)),
+			Bid:       int32(math.Round(tick.Bid * decimalFactor)),
+			VolumeAsk: float32(tick.VolumeAsk),
+			VolumeBid: float32(tick.VolumeBid),
+		}
+		if err := binary.Write(raw, binary.BigEndian, &record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return compress(t, raw.Bytes())
+}
+
+func compress(t testing.TB, raw []byte) []byte {
+	t.Helper()
+
+	compressed := new(bytes.Buffer)
+	w, err := lzma.NewWriter(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(raw); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return compressed.Bytes()
+}
+
+// MirrorPath returns the path of the hour file inside a mirror of the dukascopy datafeed tree.
+// Like the datafeed, months are zero based.
+func MirrorPath(root, symbol string, dayHour time.Time) string {
+	dayHour = dayHour.UTC()
+	y, m, d := dayHour.Date()
+
+	return filepath.Join(root, symbol, fmt.Sprintf("%04d", y), fmt.Sprintf("%02d", int(m)-1), fmt.Sprintf("%02d", d), fmt.Sprintf("%02dh_ticks.bi5", dayHour.Hour()))
+}
+
+// WriteMirror writes the hour content into a mirror of the dukascopy datafeed tree
+func WriteMirror(t testing.TB, root, symbol string, dayHour time.Time, content []byte) {
+	t.Helper()
+
+	path := MirrorPath(root, symbol, dayHour)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tick creates tick data at the given time
+func Tick(symbol string, at time.Time, ask, bid float64) *tickdata.TickData {
+	return &tickdata.TickData{
+		Symbol:    symbol,
+		Timestamp: at.UnixMilli(),
+		Ask:       ask,
+		Bid:       bid,
+		VolumeAsk: 1,
+		VolumeBid: 1,
+	}
+}
+
+// CandleRecord is a candle starting Offset after the start of its file
+type CandleRecord struct {
+	Offset time.Duration
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// EncodeCandles encodes candles into lzma compressed bi5 content
+func EncodeCandles(t testing.TB, decimalFactor float64, candles ...CandleRecord) []byte {
+	t.Helper()
+
+	raw := new(bytes.Buffer)
+	for _, c := range candles {
+		record := struct {
+			TimeSec int32
+			Open    int32
+			Close   int32
+			Low     int32
+			High    int32
+			Volume  float32
+		}{
+			TimeSec: int32(c.Offset / time.Second),
+			Open:    int32(math.Round(c.Open * decimalFactor)),
+			Close:   int32(math.Round(c.Close * decimalFactor)),
+			Low:     int32(math.Round(c.Low * decimalFactor)),
+			High:    int32(math.Round(c.High * decimalFactor)),
+			Volume:  float32(c.Volume),
+		}
+		if err := binary.Write(raw, binary.BigEndian, &record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return compress(t, raw.Bytes())
+}
+
+// WriteCandlesMirror writes the candles file into a mirror of the dukascopy datafeed tree.
+// The file name is like BID_candles_day_1.bi5, dir is the zero based month path relative to the symbol, like 2021/00.
+func WriteCandlesMirror(t testing.TB, root, symbol, dir, fileName string, content []byte) {
+	t.Helper()
+
+	path := filepath.Join(root, symbol, filepath.FromSlash(dir), fileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// FileSource returns the datafeed option reading from the mirror at root
+func FileSource(t testing.TB, root string) datafeed.Option {
+	t.Helper()
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return datafeed.WithSource((&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String())
+}
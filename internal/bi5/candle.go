@@ -0,0 +1,213 @@
+package bi5
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/pkg/errors"
+	"github.com/ulikunitz/xz/lzma"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	CANDLE_BYTES = 24
+
+	candlesFileInfix = "_candles_"
+)
+
+// CandleSide of the quotes the candles are built from
+type CandleSide string
+
+const (
+	CandleBid CandleSide = "BID"
+	CandleAsk CandleSide = "ASK"
+)
+
+// CandlePeriod of a candle. Dukascopy publishes minute candles per day, hour candles per month
+// and day candles per year.
+type CandlePeriod string
+
+const (
+	CandleMinute CandlePeriod = "min_1"
+	CandleHour   CandlePeriod = "hour_1"
+	CandleDay    CandlePeriod = "day_1"
+)
+
+// Duration of one candle
+func (p CandlePeriod) Duration() time.Duration {
+	switch p {
+	case CandleMinute:
+		return time.Minute
+	case CandleHour:
+		return time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
+
+// FileStart returns the start of the file covering t
+func (p CandlePeriod) FileStart(t time.Time) time.Time {
+	t = t.UTC()
+	switch p {
+	case CandleMinute:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case CandleHour:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// NextFileStart returns the start of the file following the one starting at fileStart
+func (p CandlePeriod) NextFileStart(fileStart time.Time) time.Time {
+	switch p {
+	case CandleMinute:
+		return fileStart.AddDate(0, 0, 1)
+	case CandleHour:
+		return fileStart.AddDate(0, 1, 0)
+	default:
+		return fileStart.AddDate(1, 0, 0)
+	}
+}
+
+func (p CandlePeriod) timeFormat() string {
+	switch p {
+	case CandleMinute:
+		return "2006-01-02"
+	case CandleHour:
+		return "2006-01"
+	default:
+		return "2006"
+	}
+}
+
+// fileDir returns the folder of the file starting at fileStart, monthOffset is -1 for the zero based datafeed months
+func (p CandlePeriod) fileDir(symbol string, fileStart time.Time, monthOffset int) string {
+	y, m, d := fileStart.Date()
+	switch p {
+	case CandleMinute:
+		return fmt.Sprintf("%s/%04d/%02d/%02d", symbol, y, int(m)+monthOffset, d)
+	case CandleHour:
+		return fmt.Sprintf("%s/%04d/%02d", symbol, y, int(m)+monthOffset)
+	default:
+		return fmt.Sprintf("%s/%04d", symbol, y)
+	}
+}
+
+func candlesFileName(side CandleSide, period CandlePeriod) string {
+	return string(side) + candlesFileInfix + string(period) + "." + ext
+}
+
+// CandleFilePath returns the cache path of the candles file covering t
+func CandleFilePath(folder, symbol string, side CandleSide, period CandlePeriod, t time.Time) string {
+	dir := period.fileDir(symbol, period.FileStart(t), 0)
+
+	return filepath.FromSlash(folder + "/download/" + dir + "/" + candlesFileName(side, period))
+}
+
+// Candle of a single period. Time is the candle start in UTC.
+type Candle struct {
+	Symbol string
+	Side   CandleSide
+	Period CandlePeriod
+	Time   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// DownloadCandles downloads the candles file covering t
+func (d Downloader) DownloadCandles(instrumentCode string, side CandleSide, period CandlePeriod, t time.Time) error {
+	return d.DownloadCandlesContext(context.Background(), instrumentCode, side, period, t)
+}
+
+// DownloadCandlesContext downloads the candles file covering t, the download is aborted once ctx is done
+func (d Downloader) DownloadCandlesContext(ctx context.Context, instrumentCode string, side CandleSide, period CandlePeriod, t time.Time) error {
+	fileStart := period.FileStart(t)
+
+	return d.download(ctx, feedFile{
+		kind:           "candle data",
+		instrumentCode: instrumentCode,
+		// Like tick data, months of the datafeed are zero based
+		url:            d.source + "/" + period.fileDir(instrumentCode, fileStart, -1) + "/" + candlesFileName(side, period),
+		targetFilePath: CandleFilePath(d.folder, instrumentCode, side, period, fileStart),
+		start:          fileStart,
+		end:            period.NextFileStart(fileStart),
+		recordBytes:    CANDLE_BYTES,
+		timeFormat:     period.timeFormat(),
+	})
+}
+
+// ReadCandles decodes the cached candles file covering t. A file missing from the cache has no candles.
+//
+//	struct.unpack(!IIIIIf)
+//	seconds since file start, open, close, low, high, volume
+func ReadCandles(folder string, metadata *instrument.Metadata, side CandleSide, period CandlePeriod, t time.Time) ([]Candle, error) {
+	fileStart := period.FileStart(t)
+	filePath := CandleFilePath(folder, metadata.Code(), side, period, fileStart)
+
+	f, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return []Candle{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to open ["+filePath+"]")
+	}
+	defer func(f *os.File) { _ = f.Close() }(f)
+
+	reader, err := lzma.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create file [%s] reader", filePath)
+	}
+
+	point := metadata.DecimalFactor()
+	candles := make([]Candle, 0)
+	record := make([]byte, CANDLE_BYTES)
+	for {
+		if _, err = io.ReadFull(reader, record); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "LZMA decode failed for file [%s]", filePath)
+		}
+
+		var timeSec, open, closePrice, low, high int32
+		var volume float32
+		buf := bytes.NewBuffer(record)
+		timeSec, err = read[int32](err, buf, "time")
+		open, err = read[int32](err, buf, "open")
+		closePrice, err = read[int32](err, buf, "close")
+		low, err = read[int32](err, buf, "low")
+		high, err = read[int32](err, buf, "high")
+		volume, err = read[float32](err, buf, "volume")
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode candle data failed for file [%s]", filePath)
+		}
+
+		candles = append(candles, Candle{
+			Symbol: metadata.Code(),
+			Side:   side,
+			Period: period,
+			Time:   fileStart.Add(time.Duration(timeSec) * time.Second),
+			Open:   float64(open) / point,
+			High:   float64(high) / point,
+			Low:    float64(low) / point,
+			Close:  float64(closePrice) / point,
+			Volume: float64(volume),
+		})
+	}
+
+	return candles, nil
+}
+
+// isCandlesFile returns true for cached BID/ASK candles files
+func isCandlesFile(name string) bool {
+	return strings.Contains(name, candlesFileInfix) && strings.HasSuffix(name, "."+ext)
+}
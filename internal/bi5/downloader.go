@@ -1,7 +1,9 @@
 package bi5
 
 import (
+	"context"
 	"fmt"
+	"github.com/edward-yakop/go-duka/api/datafeed"
 	"github.com/edward-yakop/go-duka/internal/core"
 	"github.com/edward-yakop/go-duka/internal/misc"
 	"github.com/go-resty/resty/v2"
@@ -13,64 +15,170 @@ import (
 )
 
 type Downloader struct {
-	client *resty.Client
-	folder string
+	client     *resty.Client
+	folder     string
+	source     string
+	fetcher    core.Downloader
+	revalidate datafeed.RevalidatePolicy
+	offline    bool
 }
 
-func NewDownloader(folder string) *Downloader {
+func NewDownloader(folder string, opts ...datafeed.Option) *Downloader {
+	config := datafeed.NewConfig(opts...)
+	fetcher := httpDownload
+	if config.IsFileSource() {
+		fetcher = fileDownload
+	}
+
 	return &Downloader{
-		folder: folder,
+		folder:     folder,
+		source:     config.Source(),
+		fetcher:    fetcher,
+		revalidate: config.RevalidatePolicy(),
+		offline:    config.IsOffline(),
 	}
 }
 
 func (d Downloader) Download(instrumentCode string, t time.Time) error {
+	return d.DownloadContext(context.Background(), instrumentCode, t)
+}
+
+// DownloadContext downloads the hour tick data, the download is aborted once ctx is done
+func (d Downloader) DownloadContext(ctx context.Context, instrumentCode string, t time.Time) error {
 	dayHour := misc.ToHourUTC(t)
 	year, month, day := dayHour.Date()
 	hour := dayHour.Hour()
-	targetFilePath := BiFilePath(d.folder, instrumentCode, year, int(month), day, hour)
 
-	if d.isDownloaded(targetFilePath) {
+	return d.download(ctx, feedFile{
+		kind:           "tick data",
+		instrumentCode: instrumentCode,
+		url:            fmt.Sprintf(core.DukaTmplURL, d.source, instrumentCode, year, month-1, day, hour),
+		targetFilePath: BiFilePath(d.folder, instrumentCode, year, int(month), day, hour),
+		start:          dayHour,
+		end:            dayHour.Add(time.Hour),
+		recordBytes:    TICK_BYTES,
+		timeFormat:     "2006-01-02:15H",
+	})
+}
+
+// feedFile is a datafeed file covering [start, end)
+type feedFile struct {
+	kind           string
+	instrumentCode string
+	url            string
+	targetFilePath string
+	start          time.Time
+	end            time.Time
+	recordBytes    int
+	timeFormat     string
+}
+
+func (f feedFile) symbolAndTime() string {
+	return f.instrumentCode + ": " + f.start.Format(f.timeFormat)
+}
+
+// download fetches the file into the cache, unless it's already cached or marked as missing
+func (d Downloader) download(ctx context.Context, f feedFile) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if d.isDownloaded(f) {
 		return nil
 	}
 
-	url := fmt.Sprintf(core.DukaTmplURL, instrumentCode, year, month-1, day, hour)
+	if d.offline {
+		return &datafeed.ErrNotCached{Symbol: f.instrumentCode, Hour: f.start}
+	}
+
+	// Download into a temporary file, so an interrupted download never ends up in the cache
+	tempFilePath, err := d.createTempFile(f.targetFilePath)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create "+f.kind+" ["+f.symbolAndTime()+"] temporary file")
+	}
+	defer func() { _ = os.Remove(tempFilePath) }()
 
 	var httpStatusCode int
-	httpStatusCode, filesize, err := httpDownload.Download(url, targetFilePath)
+	httpStatusCode, filesize, err := d.fetcher.Download(ctx, f.url, tempFilePath)
 	if err != nil {
-		symbolTime := d.symbolAndTime(instrumentCode, dayHour)
-		return errors.Wrap(err, "Failed to download tick data for ["+symbolTime+"]")
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		return errors.Wrap(err, "Failed to download "+f.kind+" for ["+f.symbolAndTime()+"]")
 	}
 
 	if httpStatusCode == http.StatusNotFound {
-		notFound := targetFilePath + ".notFound"
+		notFound := f.targetFilePath + MarkerNotFound.Ext()
 		err = d.createFile(notFound)
 		if err != nil {
-			symbolTime := d.symbolAndTime(instrumentCode, dayHour)
-			err = errors.Wrap(err, "Failed to create tick data ["+symbolTime+"] not found file")
-			return err
+			err = errors.Wrap(err, "Failed to create "+f.kind+" ["+f.symbolAndTime()+"] not found file")
 		}
+		return err
+	}
+
+	if httpStatusCode != http.StatusOK {
+		return errors.Errorf("Failed to download %s for [%s], unexpected http status [%d]", f.kind, f.symbolAndTime(), httpStatusCode)
 	}
 
 	if filesize == 0 {
-		err = os.Rename(targetFilePath, targetFilePath+".empty")
+		err = d.createFile(f.targetFilePath + MarkerEmpty.Ext())
 		if err != nil {
-			symbolTime := d.symbolAndTime(instrumentCode, dayHour)
-			return errors.Wrap(err, "Failed to create tick data ["+symbolTime+"] empty file")
+			return errors.Wrap(err, "Failed to create "+f.kind+" ["+f.symbolAndTime()+"] empty file")
 		}
+
+		return nil
+	}
+
+	if err = VerifyFile(tempFilePath, f.recordBytes); err != nil {
+		return errors.Wrap(err, "Downloaded "+f.kind+" for ["+f.symbolAndTime()+"] is corrupted")
+	}
+
+	if err = os.Rename(tempFilePath, f.targetFilePath); err != nil {
+		return errors.Wrap(err, "Failed to move "+f.kind+" ["+f.symbolAndTime()+"] into the cache")
 	}
 
 	return nil
 }
 
-func (d Downloader) isDownloaded(targetFile string) bool {
-	return misc.IsFileExists(targetFile) ||
-		misc.IsFileExists(targetFile+".empty") ||
-		misc.IsFileExists(targetFile+".notFound")
+// createTempFile creates a uniquely named file next to the target file, so that the final rename is atomic
+func (d Downloader) createTempFile(targetFilePath string) (string, error) {
+	dir := filepath.Dir(targetFilePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrap(err, "Create folder ["+dir+"] failed")
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(targetFilePath)+".*"+tempExt)
+	if err != nil {
+		return "", err
+	}
+	_ = f.Close()
+
+	return f.Name(), nil
 }
 
-func (d Downloader) symbolAndTime(symbol string, dayHour time.Time) string {
-	return symbol + ": " + dayHour.Format("2006-01-02:15H")
+func (d Downloader) isDownloaded(f feedFile) bool {
+	if misc.IsFileExists(f.targetFilePath) {
+		return true
+	}
+
+	now := time.Now()
+	for _, kind := range markerKinds {
+		markerPath := f.targetFilePath + kind.Ext()
+		info, err := os.Stat(markerPath)
+		if err != nil {
+			continue
+		}
+		// Markers can't be checked again without network
+		if d.offline || !d.revalidate.IsStalePeriod(f.start, f.end, info.ModTime(), now) {
+			return true
+		}
+
+		// The file might have been published since the marker was written, check again
+		_ = os.Remove(markerPath)
+	}
+
+	return false
 }
 
 func (d Downloader) createFile(path string) error {
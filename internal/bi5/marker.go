@@ -0,0 +1,88 @@
+package bi5
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/edward-yakop/go-duka/internal/misc"
+	"github.com/pkg/errors"
+)
+
+// MarkerKind of the sentinel file written in place of hour tick data
+type MarkerKind string
+
+const (
+	// MarkerNotFound dukascopy responded with not found
+	MarkerNotFound MarkerKind = "notFound"
+	// MarkerEmpty dukascopy responded with an empty file
+	MarkerEmpty MarkerKind = "empty"
+)
+
+var markerKinds = []MarkerKind{MarkerNotFound, MarkerEmpty}
+
+// Ext returns the file extension appended to the bi5 file path
+func (k MarkerKind) Ext() string {
+	return "." + string(k)
+}
+
+// Marker is a .notFound or .empty sentinel file of an hour
+type Marker struct {
+	InstrumentCode string
+	DayHour        time.Time
+	Kind           MarkerKind
+	Path           string
+	Created        time.Time
+}
+
+// ListMarkers returns markers of the instrument hours between from and to (both inclusive), in chronological order
+func ListMarkers(folder, instrumentCode string, from, to time.Time) ([]Marker, error) {
+	instrumentCode = strings.ToUpper(instrumentCode)
+	from = misc.ToHourUTC(from)
+	to = misc.ToHourUTC(to)
+	if to.Before(from) {
+		from, to = to, from
+	}
+
+	markers := make([]Marker, 0)
+	for dayHour := from; !dayHour.After(to); dayHour = dayHour.Add(time.Hour) {
+		filePath := BiFilePathTime(folder, instrumentCode, dayHour)
+		for _, kind := range markerKinds {
+			markerPath := filePath + kind.Ext()
+			info, err := os.Stat(markerPath)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return markers, errors.Wrap(err, "Failed to stat marker ["+markerPath+"]")
+			}
+
+			markers = append(markers, Marker{
+				InstrumentCode: instrumentCode,
+				DayHour:        dayHour,
+				Kind:           kind,
+				Path:           markerPath,
+				Created:        info.ModTime(),
+			})
+		}
+	}
+
+	return markers, nil
+}
+
+// ClearMarkers removes markers of the instrument hours between from and to (both inclusive),
+// so that those hours are downloaded again on next request. Returns the removed markers.
+func ClearMarkers(folder, instrumentCode string, from, to time.Time) ([]Marker, error) {
+	markers, err := ListMarkers(folder, instrumentCode, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, m := range markers {
+		if err = os.Remove(m.Path); err != nil && !os.IsNotExist(err) {
+			return markers[:i], errors.Wrap(err, "Failed to remove marker ["+m.Path+"]")
+		}
+	}
+
+	return markers, nil
+}
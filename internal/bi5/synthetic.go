@@ -0,0 +1,155 @@
+package bi5
+
+import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/internal/misc"
+	"iter"
+	"slices"
+	"time"
+)
+
+// syntheticLookbackHours bounds the hours searched back for the last quote of a leg, long enough for a weekend
+const syntheticLookbackHours = 72
+
+// downloadLegs downloads the hour of both legs and the previous hours up to the last one with a leg file,
+// so that the leg quotes carry over. Returns the first error of the hour, a previous hour failing to download
+// only stops the search, the leg then has no quote to carry over.
+func (b Bi5) downloadLegs(ctx context.Context) error {
+	var firstErr error
+	for _, leg := range b.legs {
+		if err := leg.DownloadContext(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		for prev := range leg.previousHours() {
+			if prev.DownloadContext(ctx) != nil || prev.hasFile() {
+				break
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	return firstErr
+}
+
+// previousHours yields the hours before b, most recent first, within the lookback and the instrument ticks start
+func (b Bi5) previousHours() iter.Seq[*Bi5] {
+	return func(yield func(*Bi5) bool) {
+		start := b.metadata.StartHourForTicks()
+		for h := 1; h <= syntheticLookbackHours; h++ {
+			dayHour := b.dayHour.Add(-time.Duration(h) * time.Hour)
+			if dayHour.Before(start) || !yield(New(dayHour, b.metadata, b.downloader.folder, b.opts...)) {
+				return
+			}
+		}
+	}
+}
+
+// hasFile reports whether the hour file is downloaded, any leg file for a synthetic instrument
+func (b Bi5) hasFile() bool {
+	if b.legs == nil {
+		return misc.IsFileExists(b.targetFilePath)
+	}
+
+	return slices.ContainsFunc(b.legs, (*Bi5).hasFile)
+}
+
+// isCached reports whether the hour file or its marker is downloaded, the hour of every leg for a synthetic instrument
+func (b Bi5) isCached() bool {
+	if b.legs != nil {
+		return !slices.ContainsFunc(b.legs, func(leg *Bi5) bool { return !leg.isCached() })
+	}
+
+	return misc.IsFileExists(b.targetFilePath) || slices.ContainsFunc(markerKinds, func(kind MarkerKind) bool {
+		return misc.IsFileExists(b.targetFilePath + kind.Ext())
+	})
+}
+
+// lastTickBefore returns the last tick of the previous hour with a file, nil if there is none within the lookback
+// or an hour in between isn't downloaded
+func (b Bi5) lastTickBefore() (*tickdata.TickData, error) {
+	for prev := range b.previousHours() {
+		if !prev.isCached() {
+			return nil, nil
+		}
+		if !prev.hasFile() {
+			continue
+		}
+
+		ticks, err := prev.Ticks()
+		if err != nil || len(ticks) > 0 {
+			return last(ticks), err
+		}
+	}
+
+	return nil, nil
+}
+
+func last(ticks []*tickdata.TickData) *tickdata.TickData {
+	if len(ticks) == 0 {
+		return nil
+	}
+
+	return ticks[len(ticks)-1]
+}
+
+// eachSyntheticTick merges the ticks of the legs by time. A tick is yielded on each leg tick, the quote of the other
+// leg carries over from the previous hours if it didn't tick yet within the hour. Legs ticking at the same millisecond
+// yield a single tick. The volumes are the ones of the leg that ticked, the first leg if both did.
+func (b Bi5) eachSyntheticTick(it tickdata.TickIterator) {
+	ticksA, err := b.legs[0].Ticks()
+	if err != nil {
+		it(nil, err)
+		return
+	}
+	ticksB, err := b.legs[1].Ticks()
+	if err != nil {
+		it(nil, err)
+		return
+	}
+
+	var lastA, lastB *tickdata.TickData
+	if len(ticksB) > 0 && (len(ticksA) == 0 || ticksB[0].Timestamp < ticksA[0].Timestamp) {
+		lastA, err = b.legs[0].lastTickBefore()
+	}
+	if err == nil && len(ticksA) > 0 && (len(ticksB) == 0 || ticksA[0].Timestamp < ticksB[0].Timestamp) {
+		lastB, err = b.legs[1].lastTickBefore()
+	}
+	if err != nil {
+		it(nil, err)
+		return
+	}
+
+	synthetic := b.metadata.Synthetic()
+	for i, j := 0, 0; i < len(ticksA) || j < len(ticksB); {
+		var tick *tickdata.TickData
+		if j == len(ticksB) || (i < len(ticksA) && ticksA[i].Timestamp <= ticksB[j].Timestamp) {
+			if j < len(ticksB) && ticksA[i].Timestamp == ticksB[j].Timestamp {
+				lastB = ticksB[j]
+				j++
+			}
+			lastA, tick = ticksA[i], ticksA[i]
+			i++
+		} else {
+			lastB, tick = ticksB[j], ticksB[j]
+			j++
+		}
+		if lastA == nil || lastB == nil {
+			continue
+		}
+
+		bid, ask := synthetic.Quote(lastA.Bid, lastA.Ask, lastB.Bid, lastB.Ask)
+		if !it(&tickdata.TickData{
+			Symbol:    b.InstrumentCode(),
+			Timestamp: tick.Timestamp,
+			Ask:       ask,
+			Bid:       bid,
+			VolumeAsk: tick.VolumeAsk,
+			VolumeBid: tick.VolumeBid,
+		}, nil) {
+			return
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package bi5
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+const (
+	tempExt         = ".tmp"
+	quarantineDir   = "quarantine"
+	ticksFileSuffix = "h_ticks." + ext
+)
+
+// VerifyFile checks that the file is a complete LZMA stream
+// and that the decompressed length is a multiple of recordBytes
+func VerifyFile(filePath string, recordBytes int) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return errors.Wrap(err, "Failed to open ["+filePath+"]")
+	}
+	defer func(f *os.File) { _ = f.Close() }(f)
+
+	reader, err := lzma.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return errors.Wrapf(err, "invalid LZMA header for file [%s]", filePath)
+	}
+
+	n, err := io.Copy(io.Discard, reader)
+	if err != nil {
+		return errors.Wrapf(err, "incomplete LZMA stream for file [%s]", filePath)
+	}
+	if n%int64(recordBytes) != 0 {
+		return errors.Errorf("decompressed length [%d] of file [%s] is not a multiple of [%d]", n, filePath, recordBytes)
+	}
+
+	return nil
+}
+
+// VerifyListener is called for each verified file. verifyErr is nil when the file is valid,
+// otherwise quarantinePath is where the corrupted file was moved to.
+type VerifyListener func(filePath string, verifyErr error, quarantinePath string)
+
+// VerifyResult summarizes a cache verification
+type VerifyResult struct {
+	Checked     int
+	Quarantined int
+}
+
+// recordBytesOf returns the record size of the cached bi5 file, 0 if it's not a bi5 file
+func recordBytesOf(fileName string) int {
+	switch {
+	case strings.HasSuffix(fileName, ticksFileSuffix):
+		return TICK_BYTES
+	case isCandlesFile(fileName):
+		return CANDLE_BYTES
+	default:
+		return 0
+	}
+}
+
+// VerifyCache scans tick and candle bi5 files in the download cache, optionally limited to one instrument.
+// Corrupted files are moved into the quarantine folder, so they are downloaded again on next request.
+func VerifyCache(ctx context.Context, folder, instrumentCode string, listener VerifyListener) (result VerifyResult, err error) {
+	root := filepath.Join(folder, "download")
+	if instrumentCode != "" {
+		root = filepath.Join(root, strings.ToUpper(instrumentCode))
+	}
+	if _, err = os.Stat(root); os.IsNotExist(err) {
+		return result, nil
+	}
+
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		recordBytes := recordBytesOf(entry.Name())
+		if recordBytes == 0 {
+			return nil
+		}
+
+		result.Checked++
+		verifyErr := VerifyFile(path, recordBytes)
+		var quarantinePath string
+		if verifyErr != nil {
+			if quarantinePath, walkErr = quarantine(folder, path); walkErr != nil {
+				return walkErr
+			}
+			result.Quarantined++
+		}
+		if listener != nil {
+			listener(path, verifyErr, quarantinePath)
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// quarantine moves the file from the download folder into the quarantine folder keeping its relative path
+func quarantine(folder, filePath string) (string, error) {
+	rel, err := filepath.Rel(filepath.Join(folder, "download"), filePath)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to quarantine ["+filePath+"]")
+	}
+
+	target := filepath.Join(folder, quarantineDir, rel)
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", errors.Wrap(err, "Create folder ["+filepath.Dir(target)+"] failed")
+	}
+	if err = os.Rename(filePath, target); err != nil {
+		return "", errors.Wrap(err, "Failed to quarantine ["+filePath+"]")
+	}
+
+	return target, nil
+}
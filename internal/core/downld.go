@@ -1,6 +1,8 @@
 package core
 
+import "context"
+
 // Downloader interface...
 type Downloader interface {
-	Download(URL string, toFilePath string) (httpStatusCode int, filesize int64, err error)
+	Download(ctx context.Context, URL string, toFilePath string) (httpStatusCode int, filesize int64, err error)
 }
@@ -1,14 +1,15 @@
 package core
 
 import (
+	"context"
 	"github.com/go-resty/resty/v2"
 	"log/slog"
 	"time"
 )
 
 const (
-	// "https://datafeed.dukascopy.com/datafeed/{currency}/{year}/{month:02d}/{day:02d}/{hour:02d}h_ticks.bi5"
-	DukaTmplURL = "https://datafeed.dukascopy.com/datafeed/%s/%04d/%02d/%02d/%02dh_ticks.bi5"
+	// "{root}/{currency}/{year}/{month:02d}/{day:02d}/{hour:02d}h_ticks.bi5"
+	DukaTmplURL = "%s/%s/%04d/%02d/%02d/%02dh_ticks.bi5"
 	retryTimes  = 5
 )
 
@@ -24,7 +25,7 @@ func NewDownloader() Downloader {
 	}
 }
 
-func (h HTTPDownload) Download(URL string, toFilePath string) (httpStatusCode int, filesize int64, err error) {
+func (h HTTPDownload) Download(ctx context.Context, URL string, toFilePath string) (httpStatusCode int, filesize int64, err error) {
 	slog.Debug(
 		"about to download",
 		slog.String("url", URL),
@@ -32,6 +33,7 @@ func (h HTTPDownload) Download(URL string, toFilePath string) (httpStatusCode in
 	)
 
 	resp, getErr := h.client.R().
+		SetContext(ctx).
 		SetOutput(toFilePath).
 		Get(URL)
 
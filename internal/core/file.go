@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FileDownload copies files from a local mirror of the dukascopy datafeed tree
+type FileDownload struct{}
+
+func NewFileDownloader() Downloader {
+	return &FileDownload{}
+}
+
+// Download copies the file referenced by the file:// URL.
+// A missing source file is reported as http.StatusNotFound to mirror the http downloader.
+func (FileDownload) Download(ctx context.Context, URL string, toFilePath string) (httpStatusCode int, filesize int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	slog.Debug(
+		"about to copy",
+		slog.String("url", URL),
+		slog.String("targetFilePath", toFilePath),
+	)
+
+	sourcePath, err := FileURLToPath(URL)
+	if err != nil {
+		return
+	}
+
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return http.StatusNotFound, 0, nil
+		}
+
+		err = errors.Wrap(err, "Failed to open ["+sourcePath+"]")
+		return
+	}
+	defer func(f *os.File) { _ = f.Close() }(source)
+
+	dir := filepath.Dir(toFilePath)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		err = errors.Wrap(err, "Create folder ["+dir+"] failed")
+		return
+	}
+
+	target, err := os.Create(toFilePath)
+	if err != nil {
+		err = errors.Wrap(err, "Failed to create ["+toFilePath+"]")
+		return
+	}
+	defer func(f *os.File) { _ = f.Close() }(target)
+
+	if filesize, err = io.Copy(target, source); err != nil {
+		err = errors.Wrap(err, "Failed to copy ["+sourcePath+"]")
+		return
+	}
+
+	return http.StatusOK, filesize, nil
+}
+
+// FileURLToPath converts file:///path/to/file into a local file path
+func FileURLToPath(fileURL string) (string, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid file url [%s]", fileURL)
+	}
+	if u.Scheme != "file" {
+		return "", errors.Errorf("invalid file url [%s]", fileURL)
+	}
+
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		// file:///C:/mirror => /C:/mirror
+		p = strings.TrimPrefix(p, "/")
+	}
+
+	return filepath.FromSlash(p), nil
+}
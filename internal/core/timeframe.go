@@ -1,32 +1,26 @@
 package core
 
 import (
+	"github.com/edward-yakop/go-duka/api/bars"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/api/tickdata"
 	"log/slog"
 	"regexp"
-	"strconv"
+	"time"
 )
 
 var (
 	TimeframeRegx = regexp.MustCompile(`(M|H|D|W|MN)(\d+)`)
-	tfMinute      = map[string]uint32{
-		"M":  1,
-		"H":  60,
-		"D":  24 * 60,
-		"W":  7 * 24 * 60,
-		"MN": 30 * 24 * 60,
-	}
 )
 
 // Timeframe wrapper of tick data in timeframe like: M1, M5, M15, M30, H1, H4, D1, W1, MN
 type Timeframe struct {
-	deltaTimestamp uint32 // unit second
-	startTimestamp uint32 // unit second
-	endTimestamp   uint32 // unit second
-	timeframe      uint32 // Period of data aggregation in minutes
-	period         string // M1, M5, M15, M30, H1, H4, D1, W1, MN
-	instrument     *instrument.Metadata
+	bars       bars.Timeframe
+	barStart   time.Time
+	barEnd     time.Time
+	timeframe  uint32 // Period of data aggregation in minutes
+	period     string // M1, M5, M15, M30, H1, H4, D1, W1, MN
+	instrument *instrument.Metadata
 
 	chTicks chan *tickdata.TickData
 	close   chan struct{}
@@ -35,29 +29,38 @@ type Timeframe struct {
 
 // ParseTimeframe from input string
 func ParseTimeframe(period string) (uint32, string) {
+	tf := ParseBarsTimeframe(period)
+
+	return tf.Minutes(), tf.String()
+}
+
+// ParseBarsTimeframe from input string, M1 by default
+func ParseBarsTimeframe(period string) bars.Timeframe {
 	// M15 => [M15 M 15]
 	if ss := TimeframeRegx.FindStringSubmatch(period); len(ss) == 3 {
-		n, _ := strconv.Atoi(ss[2])
-		for key, val := range tfMinute {
-			if key == ss[1] {
-				return val * uint32(n), ss[0]
-			}
+		if tf, err := bars.ParseTimeframe(ss[0]); err == nil {
+			return tf
 		}
 	}
-	return 1, "M1" // M1 by default
+
+	return bars.M1 // M1 by default
 }
 
 // NewTimeframe create an new timeframe
 func NewTimeframe(period string, instrument *instrument.Metadata, out Converter) Converter {
-	min, str := ParseTimeframe(period)
+	return NewBarsTimeframe(ParseBarsTimeframe(period), instrument, out)
+}
+
+// NewBarsTimeframe create an new timeframe aligning bars like the given bars timeframe
+func NewBarsTimeframe(barsTimeframe bars.Timeframe, instrument *instrument.Metadata, out Converter) Converter {
 	tf := &Timeframe{
-		deltaTimestamp: min * 60,
-		timeframe:      min,
-		period:         str,
-		instrument:     instrument,
-		out:            out,
-		chTicks:        make(chan *tickdata.TickData, 1024),
-		close:          make(chan struct{}, 1),
+		bars:       barsTimeframe,
+		timeframe:  barsTimeframe.Minutes(),
+		period:     barsTimeframe.String(),
+		instrument: instrument,
+		out:        out,
+		chTicks:    make(chan *tickdata.TickData, 1024),
+		close:      make(chan struct{}, 1),
 	}
 
 	go tf.worker()
@@ -96,42 +99,34 @@ func (tf *Timeframe) worker() error {
 		close(tf.close)
 	}()
 
-	var tickSeconds uint32
-	var tickBarTime uint32
-
 	for tick := range tf.chTicks {
-		// Beginning of the bar's timeline.
-		tickSeconds = uint32(tick.Timestamp / 1000)
-		tickBarTime = tickSeconds - tickSeconds%tf.deltaTimestamp
+		tickTime := tick.UTC()
 
-		if tf.startTimestamp == 0 {
-			tf.startTimestamp = tickBarTime
-			tf.endTimestamp = tickBarTime + tf.deltaTimestamp
+		if tf.barStart.IsZero() {
+			// Beginning of the bar's timeline.
+			tf.barStart = tf.bars.BarStart(tickTime)
+			tf.barEnd = tf.bars.NextBarStart(tf.barStart)
 		}
 
 		//Determines the end of the current bar.
-		if tickSeconds >= tf.endTimestamp {
+		if !tickTime.Before(tf.barEnd) {
 			// output one bar data
 			if len(barTicks) > 0 {
-				_ = tf.out.PackTicks(tf.startTimestamp, barTicks[:])
+				_ = tf.out.PackTicks(uint32(tf.barStart.Unix()), barTicks[:])
 				barTicks = barTicks[:0]
 			}
 
 			// Next bar's timeline will begin from this new tick's bar
-			tf.startTimestamp = tickBarTime
-			tf.endTimestamp = tf.startTimestamp + tf.deltaTimestamp
-
-			// start next round bar
-			barTicks = append(barTicks, tick)
-
-		} else {
-			// Tick is within the current bar's timeline, queue it
-			barTicks = append(barTicks, tick)
+			tf.barStart = tf.bars.BarStart(tickTime)
+			tf.barEnd = tf.bars.NextBarStart(tf.barStart)
 		}
+
+		// Tick is within the current bar's timeline, queue it
+		barTicks = append(barTicks, tick)
 	}
 
 	if len(barTicks) > 0 {
-		_ = tf.out.PackTicks(tf.startTimestamp, barTicks[:])
+		_ = tf.out.PackTicks(uint32(tf.barStart.Unix()), barTicks[:])
 	}
 
 	return nil
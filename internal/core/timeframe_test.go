@@ -0,0 +1,56 @@
+package core
+
+import (
+	"github.com/edward-yakop/go-duka/api/bars"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type barTimes struct {
+	times []time.Time
+}
+
+func (b *barTimes) PackTicks(barTimestamp uint32, ticks []*tickdata.TickData) error {
+	b.times = append(b.times, time.Unix(int64(barTimestamp), 0).UTC())
+	return nil
+}
+
+func (b *barTimes) Finish() error {
+	return nil
+}
+
+func packTimes(t *testing.T, tf bars.Timeframe, times ...time.Time) []time.Time {
+	out := &barTimes{}
+	converter := NewBarsTimeframe(tf, instrument.GetMetadata("EURUSD"), out)
+	ticks := make([]*tickdata.TickData, 0, len(times))
+	for _, at := range times {
+		ticks = append(ticks, &tickdata.TickData{Symbol: "EURUSD", Timestamp: at.UnixMilli(), Ask: 1.1, Bid: 1.1})
+	}
+	assert.NoError(t, converter.PackTicks(0, ticks))
+	assert.NoError(t, converter.Finish())
+
+	return out.times
+}
+
+func TestTimeframe_CalendarBars(t *testing.T) {
+	times := packTimes(t, ParseBarsTimeframe("W1"),
+		time.Date(2021, time.January, 7, 10, 0, 0, 0, time.UTC),
+		time.Date(2021, time.January, 10, 22, 0, 0, 0, time.UTC),
+	)
+	assert.Equal(t, []time.Time{
+		time.Date(2021, time.January, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2021, time.January, 10, 0, 0, 0, 0, time.UTC),
+	}, times)
+
+	times = packTimes(t, ParseBarsTimeframe("MN1"),
+		time.Date(2021, time.January, 31, 23, 0, 0, 0, time.UTC),
+		time.Date(2021, time.February, 1, 0, 0, 1, 0, time.UTC),
+	)
+	assert.Equal(t, []time.Time{
+		time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2021, time.February, 1, 0, 0, 0, 0, time.UTC),
+	}, times)
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"github.com/edward-yakop/go-duka/api/bars"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/api/tickdata"
 	"io"
@@ -12,6 +13,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // FxtFile define fxt file format
@@ -32,14 +34,15 @@ type FxtFile struct {
 	deltaTimestamp uint32
 	endTimestamp   uint32
 	timeframe      uint32
+	serverTime     bars.Alignment
 	barCount       int32
 	tickCount      int64
 	chTicks        chan *FxtTick
 	chClose        chan struct{}
 }
 
-// NewFxtFile create an new fxt file instance
-func NewFxtFile(timeframe, spread, model uint32, dest string, instrument *instrument.Metadata) *FxtFile {
+// NewFxtFile create an new fxt file instance, bar and tick times are written in the broker serverTime
+func NewFxtFile(timeframe, spread, model uint32, dest string, instrument *instrument.Metadata, serverTime bars.Alignment) *FxtFile {
 	fn := fmt.Sprintf("%s%d_%d.fxt", instrument.Code(), timeframe, model)
 	fxt := &FxtFile{
 		header:         NewHeader(405, instrument, timeframe, spread, model),
@@ -48,6 +51,7 @@ func NewFxtFile(timeframe, spread, model uint32, dest string, instrument *instru
 		chClose:        make(chan struct{}, 1),
 		deltaTimestamp: timeframe * 60,
 		timeframe:      timeframe,
+		serverTime:     serverTime,
 		instrument:     instrument,
 		model:          model,
 	}
@@ -134,26 +138,21 @@ func (f *FxtFile) PackTicks(barTimestemp uint32, ticks []*tickdata.TickData) err
 		return nil
 	}
 
-	var (
-		op = ticks[0].Bid
-		hi = ticks[0].Bid
-		lo = ticks[0].Bid
-		vo = math.Max(ticks[0].VolumeBid, 1)
-	)
-
+	bar := bars.Bar{Time: time.Unix(int64(barTimestemp), 0).UTC()}
+	serverBarTime := f.serverTime.WallClock(bar.Time).Unix()
 	for _, tick := range ticks {
+		bar.Add(tick)
 		ft := &FxtTick{
-			BarTimestamp:  uint64(barTimestemp),
-			TickTimestamp: uint32(tick.Timestamp / 1000),
-			Open:          op,
-			High:          math.Max(tick.Bid, hi),
-			Low:           math.Min(tick.Bid, lo),
-			Close:         tick.Bid,
+			BarTimestamp:  uint64(serverBarTime),
+			TickTimestamp: uint32(f.serverTime.WallClock(time.UnixMilli(tick.Timestamp)).Unix()),
+			Open:          bar.OpenBid,
+			High:          bar.HighBid,
+			Low:           bar.LowBid,
+			Close:         bar.CloseBid,
 			Volume:        uint64(math.Max(tick.VolumeBid*100, 1)),
 			LaunchExpert:  3,
 			//RealSpread:    uint32(tick.Ask - tick.Bid/f.header.PointSize),
 		}
-		vo = vo + tick.VolumeBid
 		f.chTicks <- ft
 		f.tickCount++
 	}
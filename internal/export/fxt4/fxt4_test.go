@@ -0,0 +1,46 @@
+package fxt4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/edward-yakop/go-duka/api/bars"
+	"github.com/edward-yakop/go-duka/api/instrument"
+	"github.com/edward-yakop/go-duka/api/tickdata"
+	"github.com/edward-yakop/go-duka/internal/bi5/bi5test"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFxtFile_PackTicksRunningHighLow(t *testing.T) {
+	barTime := time.Date(2021, time.January, 8, 10, 0, 0, 0, time.UTC)
+	fxt := NewFxtFile(1, 20, 0, t.TempDir(), instrument.GetMetadata("EURUSD"), bars.Alignment{})
+	assert.NoError(t, fxt.PackTicks(uint32(barTime.Unix()), []*tickdata.TickData{
+		bi5test.Tick("EURUSD", barTime.Add(time.Second), 1.22502, 1.22500),
+		bi5test.Tick("EURUSD", barTime.Add(2*time.Second), 1.22512, 1.22510),
+		bi5test.Tick("EURUSD", barTime.Add(3*time.Second), 1.22507, 1.22505),
+		bi5test.Tick("EURUSD", barTime.Add(4*time.Second), 1.22492, 1.22490),
+		bi5test.Tick("EURUSD", barTime.Add(5*time.Second), 1.22497, 1.22495),
+	}))
+	assert.NoError(t, fxt.Finish())
+
+	content, err := os.ReadFile(fxt.fpath)
+	if !assert.NoError(t, err) || !assert.Len(t, content, headerSize+5*tickSize) {
+		t.FailNow()
+	}
+
+	ticks := make([]FxtTick, 5)
+	assert.NoError(t, binary.Read(bytes.NewReader(content[headerSize:]), binary.LittleEndian, ticks))
+
+	// The high and low are the ones of the bar so far, not only of the first and current ticks
+	highs := []float64{1.22500, 1.22510, 1.22510, 1.22510, 1.22510}
+	lows := []float64{1.22500, 1.22500, 1.22500, 1.22490, 1.22490}
+	for i, tick := range ticks {
+		assert.Equal(t, uint64(barTime.Unix()), tick.BarTimestamp)
+		assert.Equal(t, 1.22500, tick.Open)
+		assert.Equal(t, highs[i], tick.High, "tick %d", i)
+		assert.Equal(t, lows[i], tick.Low, "tick %d", i)
+	}
+	assert.Equal(t, 1.22495, ticks[4].Close)
+}
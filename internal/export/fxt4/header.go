@@ -163,12 +163,25 @@ func NewHeader(version uint32, instrument *instrument.Metadata, timeframe, sprea
 		FirstBar: 1,
 	}
 
+	if digits := instrument.Digits(); digits > 0 {
+		h.Digits = uint32(digits)
+		h.PointSize = instrument.PointSize()
+	}
+
 	symbol := instrument.Code()
+	baseCurrency := instrument.BaseCurrency()
+	if baseCurrency == "" {
+		// Indices, commodities and stocks are margined in their quote currency
+		baseCurrency = instrument.QuoteCurrency()
+	}
+	if baseCurrency == "" && len(symbol) >= 3 {
+		baseCurrency = symbol[:3]
+	}
 	_, _ = misc.ToFixBytes(h.Description[:], "Copyright 2001-2017, MetaQuotes Software Corp.")
 	_, _ = misc.ToFixBytes(h.ServerName[:], "Beijing MoreU Tech.")
 	_, _ = misc.ToFixBytes(h.Symbol[:], symbol)
-	_, _ = misc.ToFixBytes(h.BaseCurrency[:], symbol[:3])
-	_, _ = misc.ToFixBytes(h.MarginCurrency[:], symbol[:3])
+	_, _ = misc.ToFixBytes(h.BaseCurrency[:], baseCurrency)
+	_, _ = misc.ToFixBytes(h.MarginCurrency[:], baseCurrency)
 
 	return h
 }
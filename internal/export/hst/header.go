@@ -47,6 +47,9 @@ func NewHeader(timeframe uint32, instrument *instrument.Metadata) *Header {
 		Period:   timeframe,
 		Digits:   5, // Digits, using the default value of HST format
 	}
+	if digits := instrument.Digits(); digits > 0 {
+		h.Digits = uint32(digits)
+	}
 
 	_, _ = misc.ToFixBytes(h.Symbol[:], instrument.Code())
 	_, _ = misc.ToFixBytes(h.Copyright[:], "##(C)opyright 2017, MetaQuotes Software Corp.")
@@ -2,12 +2,14 @@ package hst
 
 import (
 	"fmt"
+	"github.com/edward-yakop/go-duka/api/bars"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/api/tickdata"
 	"log/slog"
 	"math"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // HST401 MT4 history data format .hst with version 401
@@ -17,19 +19,21 @@ type HST401 struct {
 	instrument *instrument.Metadata
 	spread     uint32
 	timefame   uint32
+	serverTime bars.Alignment
 	barCount   int64
 	chBars     chan *BarData
 	chClose    chan struct{}
 }
 
-// NewHST create a HST convertor
-func NewHST(timefame, spread uint32, instrument *instrument.Metadata, dest string) *HST401 {
+// NewHST create a HST convertor, bar times are written in the broker serverTime
+func NewHST(timefame, spread uint32, instrument *instrument.Metadata, dest string, serverTime bars.Alignment) *HST401 {
 	hst := &HST401{
 		header:     NewHeader(timefame, instrument),
 		dest:       dest,
 		instrument: instrument,
 		spread:     spread,
 		timefame:   timefame,
+		serverTime: serverTime,
 		chBars:     make(chan *BarData, 128),
 		chClose:    make(chan struct{}, 1),
 	}
@@ -120,23 +124,17 @@ func (h *HST401) PackTicks(barTimestamp uint32, ticks []*tickdata.TickData) erro
 		return nil
 	}
 
+	barTime := time.Unix(int64(barTimestamp), 0).UTC()
+	aggregated := bars.Aggregate(barTime, ticks)
 	bar := &BarData{
-		CTM:   uint64(barTimestamp), //uint32(ticks[0].Timestamp / 1000),
-		Open:  ticks[0].Bid,
-		Low:   ticks[0].Bid,
-		High:  ticks[0].Bid,
-		Close: ticks[0].Bid,
+		CTM:    uint64(h.serverTime.WallClock(barTime).Unix()), //uint32(ticks[0].Timestamp / 1000),
+		Open:   aggregated.OpenBid,
+		Low:    aggregated.LowBid,
+		High:   aggregated.HighBid,
+		Close:  aggregated.CloseBid,
+		Volume: uint64(math.Max(aggregated.VolumeBid, 1)),
 	}
 
-	var totalVol float64
-	for _, tick := range ticks {
-		bar.Close = tick.Bid
-		bar.Low = math.Min(tick.Bid, bar.Low)
-		bar.High = math.Max(tick.Bid, bar.High)
-		totalVol = totalVol + tick.VolumeBid /*+tick.VolumeAsk*/
-	}
-	bar.Volume = uint64(math.Max(totalVol, 1))
-
 	select {
 	case h.chBars <- bar:
 		//log.Trace("Bar %d: %v.", h.barCount, bar)
@@ -1,6 +1,8 @@
 package tickdata
 
 import (
+	"context"
+	"github.com/edward-yakop/go-duka/api/datafeed"
 	"github.com/edward-yakop/go-duka/api/instrument"
 	"github.com/edward-yakop/go-duka/api/tickdata"
 	"github.com/edward-yakop/go-duka/internal/bi5"
@@ -76,14 +78,11 @@ func (d Day) EachTick(it tickdata.TickIterator) {
 }
 
 func (d *Day) append(dayHour time.Time, bi *bi5.Bi5, err error) {
-	d.results = append(
-		d.results,
-		&dayHourResult{
-			time: dayHour,
-			bi:   bi,
-			err:  err,
-		},
-	)
+	d.resultCh <- &dayHourResult{
+		time: dayHour,
+		bi:   bi,
+		err:  err,
+	}
 }
 
 func (d *Day) postConstruct() {
@@ -96,7 +95,13 @@ func (d *Day) postConstruct() {
 	})
 }
 
-func FetchDay(instrument *instrument.Metadata, day time.Time, folderPath string) (result tickdata.Day, err error) {
+func FetchDay(instrument *instrument.Metadata, day time.Time, folderPath string, opts ...datafeed.Option) (result tickdata.Day, err error) {
+	return FetchDayContext(context.Background(), instrument, day, folderPath, opts...)
+}
+
+// FetchDayContext downloads the 24 hours of the day. Once ctx is done, pending downloads are skipped
+// and ctx.Err() is returned.
+func FetchDayContext(ctx context.Context, instrument *instrument.Metadata, day time.Time, folderPath string, opts ...datafeed.Option) (result tickdata.Day, err error) {
 	day = day.UTC()
 	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
 
@@ -104,10 +109,14 @@ func FetchDay(instrument *instrument.Metadata, day time.Time, folderPath string)
 	hours := make(chan int)
 
 	go func() {
+		defer close(hours)
 		for i := 0; i < 24; i++ {
-			hours <- i
+			select {
+			case hours <- i:
+			case <-ctx.Done():
+				return
+			}
 		}
-		close(hours)
 	}()
 
 	var wg sync.WaitGroup
@@ -118,10 +127,10 @@ func FetchDay(instrument *instrument.Metadata, day time.Time, folderPath string)
 			defer wg.Done()
 			for hour := range hours {
 				dayHour := day.Add(time.Duration(hour) * time.Hour)
-				bi := bi5.New(dayHour, instrument, folderPath)
-				derr := bi.Download()
+				bi := bi5.New(dayHour, instrument, folderPath, opts...)
+				derr := bi.DownloadContext(ctx)
 				if derr != nil {
-					derr = errors.Wrap(err, "Download Bi5 ["+dayHour.Format("2006-01-02 15")+"] failed")
+					derr = errors.Wrap(derr, "Download Bi5 ["+dayHour.Format("2006-01-02 15")+"] failed")
 				}
 				td.append(dayHour, bi, derr)
 			}
@@ -129,8 +138,19 @@ func FetchDay(instrument *instrument.Metadata, day time.Time, folderPath string)
 	}
 
 	wg.Wait()
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	td.postConstruct()
 
+	// In offline mode, fail fast on the first hour missing from the cache
+	for _, r := range td.results {
+		var notCached *datafeed.ErrNotCached
+		if errors.As(r.err, &notCached) {
+			return nil, r.err
+		}
+	}
+
 	result = td
 	return
 }
@@ -140,6 +160,6 @@ func newDay(instrument *instrument.Metadata, time time.Time) *Day {
 		instrument: instrument,
 		time:       time,
 		results:    make([]*dayHourResult, 0),
-		resultCh:   make(chan *dayHourResult),
+		resultCh:   make(chan *dayHourResult, 24),
 	}
 }
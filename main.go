@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"github.com/edward-yakop/go-duka/api/datafeed"
 	"github.com/edward-yakop/go-duka/internal/app"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
+	_ "time/tzdata" // Ensure -tz zones are available on every platform
 
 	"github.com/edward-yakop/go-duka/internal/export/fxt4"
 )
@@ -49,7 +54,16 @@ func main() {
 		"dump given file format")
 	flag.StringVar(&args.Period,
 		"timeframe", "M1",
-		"timeframe values: M1, M5, M15, M30, H1, H4, D1, W1, MN (Comma separated list)")
+		"timeframe values: M1, M5, M15, M30, H1, H4, D1, W1, MN1 (Comma separated list)")
+	flag.StringVar(&args.WeekStart,
+		"week-start", "sunday",
+		"day W1 bars open on, values: sunday, monday")
+	flag.StringVar(&args.Timezone,
+		"tz", "UTC",
+		"timezone bars are aligned on, an offset like +02:00, an IANA zone like Europe/Athens or ny-close for daily bars closing at 17:00 New York")
+	flag.StringVar(&args.ServerTime,
+		"server-time", "",
+		"broker server time of the fxt/hst exports, an offset like +02:00, an IANA zone or ny-close for the common GMT+2/+3 brokers, bars are aligned on it")
 	flag.StringVar(&args.Symbol,
 		"symbol", "",
 		"symbol list using format, like: EURUSD EURGBP (*required)")
@@ -59,6 +73,24 @@ func main() {
 	flag.StringVar(&args.End,
 		"end", end,
 		"end date format YYYY-MM-DD")
+	flag.StringVar(&args.Source,
+		"source", datafeed.DefaultURL,
+		"datafeed root, either http(s):// or a file:// mirror of the dukascopy datafeed tree")
+	flag.BoolVar(&args.Offline,
+		"offline", false,
+		"only read the bi5 cache in the output directory, fail on the first hour missing from the cache")
+	flag.BoolVar(&args.Strict,
+		"strict", false,
+		"fail when -start is before the instrument tick data instead of skipping the missing days")
+	flag.BoolVar(&args.Clean,
+		"clean", false,
+		"drop duplicate, crossed or zero quote ticks and price spikes before the export")
+	flag.Float64Var(&args.CleanSpread,
+		"clean-spread", 50,
+		"with -clean, drop ticks more than this multiple of the median spread away from the recent prices, 0 disables")
+	flag.Float64Var(&args.CleanSigma,
+		"clean-sigma", 0,
+		"with -clean, drop ticks more than this many standard deviations away from the recent prices, 0 disables")
 	flag.StringVar(&args.Output,
 		"output", ".",
 		"destination directory to save the output file")
@@ -74,6 +106,51 @@ func main() {
 	flag.BoolVar(&args.Header,
 		"header", false,
 		"save csv with header")
+	flag.StringVar(&args.Markers,
+		"markers", "",
+		"list or clear the .notFound/.empty markers of -symbol between -start and -end, values: list, clear")
+	flag.DurationVar(&args.RevalidateRecent,
+		"revalidate-recent", datafeed.DefaultRevalidatePolicy.Recent,
+		"check again the .notFound/.empty markers of hours newer than the given age, negative to disable")
+	flag.DurationVar(&args.RevalidateSettle,
+		"revalidate-settle", datafeed.DefaultRevalidatePolicy.Settle,
+		"check again the .notFound/.empty markers created within the given duration after their hour, negative to disable")
+	flag.BoolVar(&args.Verify,
+		"verify", false,
+		"verify the bi5 cache in the output directory (limited to -symbol if set) and quarantine corrupted files")
+	flag.BoolVar(&args.RefreshInstruments,
+		"refresh-instruments", false,
+		"retrieve the latest instrument metadata instead of the embedded snapshot, cached in the output directory for a day")
+	flag.StringVar(&args.Synthetic,
+		"synthetic", "",
+		"synthetic instruments priced from the ticks of two instruments, usable as -symbol, like: EURNOK=EURUSD*USDNOK,EURGBP=EURUSD/GBPUSD")
+	flag.BoolVar(&args.List,
+		"list", false,
+		"list the instruments matching -query, -group, -currency and -available")
+	flag.StringVar(&args.Query,
+		"query", "",
+		"instrument code, name or description to search for, like: usa500")
+	flag.StringVar(&args.Group,
+		"group", "",
+		"instrument group, values: fx_major, fx_minor, fx_exotic, metals, indices, commodities, crypto, stocks, other")
+	flag.StringVar(&args.Currency,
+		"currency", "",
+		"base or quote currency of the instrument, like: USD")
+	flag.StringVar(&args.Available,
+		"available", "",
+		"only instruments with tick data at the given date, format YYYY-MM-DD")
+	flag.BoolVar(&args.Json,
+		"json", false,
+		"print the instrument list or the journal report as json")
+	flag.StringVar(&args.Journal,
+		"journal", "",
+		"analyze the trades of the CSV file with columns symbol, side, open_time, open_price, close_time, close_price and optional sl, tp, offset")
+	flag.StringVar(&args.JournalReport,
+		"journal-report", "",
+		"file to write the journal report to, printed when blank")
+	flag.StringVar(&args.BrokerOffset,
+		"broker-offset", "UTC",
+		"broker time of the journal, an offset like +02:00, an IANA zone or ny-close, overridden by the offset column")
 	flag.BoolVar(&args.Verbose,
 		"verbose", false,
 		"verbose output trace log")
@@ -95,6 +172,48 @@ func main() {
 		))
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if args.RefreshInstruments {
+		if err := app.RefreshInstruments(ctx, args); err != nil {
+			slog.Warn("Using embedded instrument metadata", slog.Any("error", err))
+		}
+	}
+
+	if err := app.RegisterSynthetics(args); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+
+	if args.List {
+		if err := app.List(args, os.Stdout); err != nil {
+			fmt.Printf("Error: %s\n", err)
+		}
+		return
+	}
+
+	if args.Journal != "" {
+		if err := journal(ctx, args); err != nil {
+			fmt.Printf("Error: %s\n", err)
+		}
+		return
+	}
+
+	if args.Verify {
+		if _, err := app.Verify(ctx, args); err != nil {
+			fmt.Printf("Error: %s\n", err)
+		}
+		return
+	}
+
+	if args.Markers != "" {
+		if err := app.Markers(args, os.Stdout); err != nil {
+			fmt.Printf("Error: %s\n", err)
+		}
+		return
+	}
+
 	if args.Dump != "" {
 		if filepath.Ext(args.Dump) == ".fxt" {
 			fxt4.DumpFile(args.Dump, args.Header, nil)
@@ -115,14 +234,40 @@ func main() {
 	}
 
 	fmt.Printf("    Output: %s\n", opt.Folder)
+	fmt.Printf("    Source: %s\n", opt.Source)
+	fmt.Printf("   Offline: %t\n", opt.Offline)
+	fmt.Printf("     Clean: %t\n", opt.Clean != nil)
 	fmt.Printf("    Instrument: %s\n", opt.Instrument.Code())
 	fmt.Printf("    Spread: %d\n", opt.Spread)
 	fmt.Printf("      Mode: %d\n", opt.Mode)
 	fmt.Printf(" Timeframe: %s\n", opt.Periods)
+	fmt.Printf(" WeekStart: %s\n", opt.WeekStart)
+	fmt.Printf("  Timezone: %s\n", opt.Alignment)
+	fmt.Printf("ServerTime: %s\n", opt.ServerTime)
 	fmt.Printf("    Format: %s\n", opt.Format)
 	fmt.Printf(" CsvHeader: %t\n", opt.CsvHeader)
 	fmt.Printf(" StartDate: %s\n", opt.Start.Format("2006-01-02:15H"))
 	fmt.Printf("   EndDate: %s\n", opt.End.Format("2006-01-02:15H"))
 
-	_ = app.NewApp(opt).Execute()
+	if err = app.NewApp(opt).ExecuteContext(ctx); err != nil {
+		fmt.Printf("Error: %s\n", err)
+	}
+}
+
+// journal writes the report to the journal-report file, or prints it
+func journal(ctx context.Context, args app.ArgsList) error {
+	if args.JournalReport == "" {
+		return app.Journal(ctx, args, os.Stdout)
+	}
+
+	f, err := os.Create(args.JournalReport)
+	if err != nil {
+		return err
+	}
+	if err = app.Journal(ctx, args, f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
 }